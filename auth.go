@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/Lanrey-waju/gator.git/internal/database"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// sessionTTL is how long a login lasts for a user with a password.
+	sessionTTL = 30 * 24 * time.Hour
+	// minPasswordLength is the shortest password passwd accepts.
+	minPasswordLength = 8
+)
+
+// hashToken is how session tokens are stored, so that reading the sessions
+// table does not let anyone log in.
+func hashToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
+
+// startSession logs user in: users with a password get a new session token,
+// and users without one are simply made current.
+func startSession(ctx context.Context, s *state, user database.User) error {
+	if !user.PasswordHash.Valid {
+		return s.cfg.SetSession(user.Name, "", time.Time{})
+	}
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return err
+	}
+	token := hex.EncodeToString(buf)
+	now := time.Now().UTC()
+	err := s.db.CreateSession(ctx, database.CreateSessionParams{
+		TokenHash: hashToken(token),
+		UserID:    user.ID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(sessionTTL),
+	})
+	if err != nil {
+		return fmt.Errorf("error creating session: %v", err)
+	}
+	// tidy up while we're here; failing to doesn't stop the login
+	s.db.DeleteExpiredSessions(ctx, now)
+	return s.cfg.SetSession(user.Name, token, now.Add(sessionTTL))
+}
+
+// checkSession makes sure the config file holds a live session for user,
+// if user has a password.
+func checkSession(ctx context.Context, s *state, user database.User) error {
+	if !user.PasswordHash.Valid {
+		return nil
+	}
+	relogin := fmt.Sprintf("run gator login %s", user.Name)
+	if s.cfg.SessionToken == "" {
+		return fmt.Errorf("%s has a password; %s", user.Name, relogin)
+	}
+	if s.cfg.SessionExpiresAt != nil && time.Now().After(*s.cfg.SessionExpiresAt) {
+		return fmt.Errorf("your session has expired; %s", relogin)
+	}
+	session, err := s.db.GetSession(ctx, database.GetSessionParams{
+		TokenHash: hashToken(s.cfg.SessionToken),
+		ExpiresAt: time.Now().UTC(),
+	})
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("your session has expired or was revoked; %s", relogin)
+	} else if err != nil {
+		return fmt.Errorf("error checking session: %v", err)
+	}
+	if session.UserID != user.ID {
+		return fmt.Errorf("your session belongs to another user; %s", relogin)
+	}
+	return nil
+}
+
+// checkPassword prompts for user's password, if they have one.
+func checkPassword(user database.User) error {
+	if !user.PasswordHash.Valid {
+		return nil
+	}
+	password, err := promptSecret("Password: ")
+	if err != nil {
+		return fmt.Errorf("error reading password: %v", err)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash.String), []byte(password)) != nil {
+		return errors.New("incorrect password")
+	}
+	return nil
+}
+
+// promptNewPassword asks for a new password twice and returns its hash.
+func promptNewPassword() (string, error) {
+	password, err := promptSecret("New password: ")
+	if err != nil {
+		return "", fmt.Errorf("error reading password: %v", err)
+	}
+	if len(password) < minPasswordLength {
+		return "", fmt.Errorf("password must be at least %d characters", minPasswordLength)
+	}
+	again, err := promptSecret("Repeat new password: ")
+	if err != nil {
+		return "", fmt.Errorf("error reading password: %v", err)
+	}
+	if again != password {
+		return "", errors.New("passwords don't match")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// handlerPasswd sets, changes or, with --clear, removes the logged-in
+// user's password. Either way every other session is signed out.
+func handlerPasswd(s *state, cmd command, user database.User) error {
+	fs := flag.NewFlagSet("passwd", flag.ContinueOnError)
+	remove := fs.Bool("clear", false, "remove the password")
+	if _, err := parseArgs(fs, cmd.arg); err != nil {
+		return err
+	}
+	if *remove && !user.PasswordHash.Valid {
+		return fmt.Errorf("%s has no password", user.Name)
+	}
+	if err := checkPassword(user); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	user.PasswordHash = sql.NullString{}
+	if !*remove {
+		hash, err := promptNewPassword()
+		if err != nil {
+			return err
+		}
+		user.PasswordHash = sql.NullString{String: hash, Valid: true}
+	}
+	err := s.db.SetUserPassword(ctx, database.SetUserPasswordParams{
+		PasswordHash: user.PasswordHash,
+		UpdatedAt:    time.Now().UTC(),
+		ID:           user.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("error saving password: %v", err)
+	}
+	if err := s.db.DeleteSessionsForUser(ctx, user.ID); err != nil {
+		return fmt.Errorf("error signing out other sessions: %v", err)
+	}
+	if err := startSession(ctx, s, user); err != nil {
+		return err
+	}
+	if *remove {
+		fmt.Printf("Password removed for %s\n", user.Name)
+	} else {
+		fmt.Printf("Password set for %s\n", user.Name)
+	}
+	return nil
+}
+
+func logoutHandler(s *state, cmd command) error {
+	if s.cfg.SessionToken != "" {
+		if err := s.db.DeleteSession(context.Background(), hashToken(s.cfg.SessionToken)); err != nil {
+			return fmt.Errorf("error ending session: %v", err)
+		}
+	}
+	if err := s.cfg.SetUser(""); err != nil {
+		return err
+	}
+	fmt.Println("Logged out")
+	return nil
+}
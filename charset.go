@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+var errCharset = errors.New("unable to decode feed")
+
+var xmlEncoding = regexp.MustCompile(`^\s*<\?xml[^>]*\bencoding\s*=\s*["']([A-Za-z0-9._:-]+)["']`)
+
+// detectCharset works out the encoding of a feed body. A byte order mark
+// wins, then the charset from the Content-Type header, then the XML
+// declaration. Servers frequently label legacy feeds as UTF-8, so the
+// header is ignored when it claims UTF-8 but the body is not valid UTF-8.
+func detectCharset(contentType string, body []byte) string {
+	switch {
+	case bytes.HasPrefix(body, []byte{0xEF, 0xBB, 0xBF}):
+		return "utf-8"
+	case bytes.HasPrefix(body, []byte{0xFE, 0xFF}):
+		return "utf-16be"
+	case bytes.HasPrefix(body, []byte{0xFF, 0xFE}):
+		return "utf-16le"
+	}
+
+	declared := ""
+	if m := xmlEncoding.FindSubmatch(body[:min(len(body), 1024)]); m != nil {
+		declared = strings.ToLower(string(m[1]))
+	}
+	if _, params, err := mime.ParseMediaType(contentType); err == nil && params["charset"] != "" {
+		charset := strings.ToLower(strings.Trim(params["charset"], `"' `))
+		if !isUTF8Label(charset) || utf8.Valid(body) || declared == "" {
+			return charset
+		}
+	}
+	if declared != "" {
+		return declared
+	}
+	return "utf-8"
+}
+
+// toUTF8 transcodes a feed body to UTF-8 so that it can be handed to the
+// XML decoder, which only understands UTF-8 on its own.
+func toUTF8(contentType string, body []byte) ([]byte, error) {
+	charset := detectCharset(contentType, body)
+	if isUTF8Label(charset) {
+		return bytes.TrimPrefix(body, []byte{0xEF, 0xBB, 0xBF}), nil
+	}
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unsupported charset %q", errCharset, charset)
+	}
+	decoded, err := enc.NewDecoder().Bytes(body)
+	if err != nil {
+		return nil, fmt.Errorf("%w as %s: %v", errCharset, charset, err)
+	}
+	return bytes.TrimPrefix(decoded, []byte{0xEF, 0xBB, 0xBF}), nil
+}
+
+func isUTF8Label(charset string) bool {
+	return charset == "utf-8" || charset == "utf8"
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// feedIn returns a small feed whose title is title, encoded with enc and
+// declaring declared in its XML declaration when that is not empty.
+func feedIn(t *testing.T, enc encoding.Encoding, declared, title string) []byte {
+	t.Helper()
+	decl := `<?xml version="1.0"?>`
+	if declared != "" {
+		decl = `<?xml version="1.0" encoding="` + declared + `"?>`
+	}
+	doc := decl + "\n<rss><channel><title>" + title + "</title></channel></rss>"
+	body, err := enc.NewEncoder().Bytes([]byte(doc))
+	if err != nil {
+		t.Fatalf("error encoding sample feed: %v", err)
+	}
+	return body
+}
+
+func TestToUTF8LegacyCharsets(t *testing.T) {
+	tests := []struct {
+		name        string
+		enc         encoding.Encoding
+		contentType string
+		declared    string
+		title       string
+	}{
+		{"windows-1252 from declaration", charmap.Windows1252, "application/rss+xml", "windows-1252", "Café “quotes” – €5"},
+		{"windows-1252 from header", charmap.Windows1252, "application/rss+xml; charset=windows-1252", "", "Naïve façade — 10€"},
+		{"ISO-8859-1 from declaration", charmap.ISO8859_1, "text/xml", "ISO-8859-1", "Größe und Übermaß"},
+		{"ISO-8859-1 from quoted header", charmap.ISO8859_1, `text/xml; charset="iso-8859-1"`, "", "Señor Niño"},
+		{"latin1 alias", charmap.ISO8859_1, "text/xml; charset=latin1", "", "Ångström"},
+		{"Shift_JIS from declaration", japanese.ShiftJIS, "application/xml", "Shift_JIS", "日本語のフィード"},
+		{"Shift_JIS from header", japanese.ShiftJIS, "application/xml; charset=shift_jis", "", "ニュース速報"},
+		{"KOI8-R from declaration", charmap.KOI8R, "application/rss+xml", "KOI8-R", "Новости дня"},
+		{"KOI8-R from header", charmap.KOI8R, "application/rss+xml; charset=koi8-r", "", "Привет, мир"},
+		{"header claims UTF-8 for a legacy body", charmap.Windows1252, "application/rss+xml; charset=utf-8", "windows-1252", "Crème brûlée"},
+		{"header wins over declaration", charmap.KOI8R, "text/xml; charset=koi8-r", "windows-1252", "Москва"},
+		{"plain UTF-8", encoding.Nop, "application/rss+xml", "", "Déjà vu ✓"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := feedIn(t, tt.enc, tt.declared, tt.title)
+			got, err := toUTF8(tt.contentType, body)
+			if err != nil {
+				t.Fatalf("toUTF8 returned error: %v", err)
+			}
+			if !strings.Contains(string(got), "<title>"+tt.title+"</title>") {
+				t.Errorf("toUTF8 = %q, want it to contain the title %q", got, tt.title)
+			}
+		})
+	}
+}
+
+func TestDetectCharsetByteOrderMark(t *testing.T) {
+	tests := []struct {
+		name string
+		enc  encoding.Encoding
+		want string
+	}{
+		{"UTF-8 BOM", unicode.UTF8BOM, "utf-8"},
+		{"UTF-16BE BOM", unicode.UTF16(unicode.BigEndian, unicode.UseBOM), "utf-16be"},
+		{"UTF-16LE BOM", unicode.UTF16(unicode.LittleEndian, unicode.UseBOM), "utf-16le"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the BOM wins even over a header and declaration saying otherwise
+			body := feedIn(t, tt.enc, "windows-1252", "Überschrift")
+			if got := detectCharset("text/xml; charset=koi8-r", body); got != tt.want {
+				t.Errorf("detectCharset = %q, want %q", got, tt.want)
+			}
+			got, err := toUTF8("text/xml; charset=koi8-r", body)
+			if err != nil {
+				t.Fatalf("toUTF8 returned error: %v", err)
+			}
+			if !strings.HasPrefix(string(got), "<?xml") {
+				t.Errorf("toUTF8 left a byte order mark: %q", got[:min(len(got), 8)])
+			}
+			if !strings.Contains(string(got), "<title>Überschrift</title>") {
+				t.Errorf("toUTF8 = %q, want it to contain the title", got)
+			}
+		})
+	}
+}
+
+func TestDetectCharsetDefaults(t *testing.T) {
+	body := []byte(`<?xml version="1.0"?><rss/>`)
+	if got := detectCharset("", body); got != "utf-8" {
+		t.Errorf("detectCharset with no hints = %q, want utf-8", got)
+	}
+	if got := detectCharset("text/xml; charset=UTF-8", body); got != "utf-8" {
+		t.Errorf("detectCharset with a UTF-8 header = %q, want utf-8", got)
+	}
+	single := []byte(`<?xml version='1.0' encoding='ISO-8859-15'?><rss/>`)
+	if got := detectCharset("text/xml", single); got != "iso-8859-15" {
+		t.Errorf("detectCharset with a single-quoted declaration = %q, want iso-8859-15", got)
+	}
+}
+
+func TestToUTF8UnsupportedCharset(t *testing.T) {
+	_, err := toUTF8("text/xml; charset=x-made-up", []byte("<rss/>"))
+	if !errors.Is(err, errCharset) {
+		t.Errorf("toUTF8 with an unknown charset returned %v, want errCharset", err)
+	}
+}
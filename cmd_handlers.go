@@ -4,8 +4,15 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"flag"
 	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sort"
 	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/Lanrey-waju/gator.git/internal/config"
@@ -14,8 +21,13 @@ import (
 )
 
 type state struct {
-	db  *database.Queries
-	cfg *config.Config
+	db      *database.Queries
+	conn    *sql.DB
+	cfg     *config.Config
+	fetcher *fetcher
+	// globalArgs are the flags given before the command name, passed on
+	// when gator re-runs itself, as "daemon start" does.
+	globalArgs []string
 }
 
 type command struct {
@@ -39,6 +51,15 @@ func (c *commands) run(s *state, cmd command) error {
 	return handler(s, cmd)
 }
 
+// dispatch runs the subcommand named by the first argument, so that a group
+// of commands such as "feed set-auth" can be registered under one name.
+func (c *commands) dispatch(s *state, cmd command) error {
+	if len(cmd.arg) < 1 {
+		return fmt.Errorf("%s expects a subcommand", cmd.name)
+	}
+	return c.run(s, command{name: cmd.arg[0], arg: cmd.arg[1:]})
+}
+
 func loginHandler(s *state, cmd command) error {
 	if len(cmd.arg) < 1 {
 		return errors.New("gator expects at least one argument: the username")
@@ -48,8 +69,10 @@ func loginHandler(s *state, cmd command) error {
 	if err != nil {
 		return fmt.Errorf("error: %v", err)
 	}
-	err = s.cfg.SetUser(user.Name)
-	if err != nil {
+	if err := checkPassword(user); err != nil {
+		return err
+	}
+	if err := startSession(context.Background(), s, user); err != nil {
 		return err
 	}
 	fmt.Printf("%s has been set", username)
@@ -57,10 +80,16 @@ func loginHandler(s *state, cmd command) error {
 }
 
 func registerHandler(s *state, cmd command) error {
-	if len(cmd.arg) < 1 {
+	fs := flag.NewFlagSet("register", flag.ContinueOnError)
+	withPassword := fs.Bool("password", false, "protect the account with a password, which is prompted for")
+	args, err := parseArgs(fs, cmd.arg)
+	if err != nil {
+		return err
+	}
+	if len(args) < 1 {
 		return errors.New("gator expects at least one argument: the username")
 	}
-	username := cmd.arg[0]
+	username := args[0]
 	user, err := s.db.GetUserByName(context.Background(), username)
 	if err == sql.ErrNoRows {
 		user, err = s.db.CreateUser(context.Background(), database.CreateUserParams{
@@ -69,10 +98,28 @@ func registerHandler(s *state, cmd command) error {
 			UpdatedAt: time.Now().UTC(),
 			Name:      username,
 		})
-		if err != nil {
+		if isUniqueViolation(err) {
+			// someone registered the same name since the lookup above
+			return fmt.Errorf("user already exists")
+		} else if err != nil {
 			return fmt.Errorf("error: %v", err)
 		}
-		if err = s.cfg.SetUser(username); err != nil {
+		if *withPassword {
+			hash, err := promptNewPassword()
+			if err != nil {
+				return fmt.Errorf("%s was created without a password: %v", username, err)
+			}
+			user.PasswordHash = sql.NullString{String: hash, Valid: true}
+			err = s.db.SetUserPassword(context.Background(), database.SetUserPasswordParams{
+				PasswordHash: user.PasswordHash,
+				UpdatedAt:    time.Now().UTC(),
+				ID:           user.ID,
+			})
+			if err != nil {
+				return fmt.Errorf("error saving password: %v", err)
+			}
+		}
+		if err = startSession(context.Background(), s, user); err != nil {
 			return fmt.Errorf("error: %v", err)
 		}
 		fmt.Printf("%s was created. ID: %s, Created At: %v, Updated At: %v, name: %v", username, user.ID, user.CreatedAt, user.UpdatedAt, user.Name)
@@ -85,26 +132,21 @@ func registerHandler(s *state, cmd command) error {
 
 }
 
-func resetHandler(s *state, cmd command) error {
-	if err := s.db.DeleteUsers(context.Background()); err != nil {
-		return fmt.Errorf("error resetting database: %v", err)
-	}
-	if err := s.cfg.SetUser("Nil"); err != nil {
-		return fmt.Errorf("Error setting user to NIL: %v\n", err)
-	}
-	fmt.Printf("Reset successful!\n")
-	return nil
-
-}
-
 func getUsersHandler(s *state, cmd command) error {
 	users, err := s.db.GetUsers(context.Background())
 	if err != nil {
 		return fmt.Errorf("error retrieving users: %v\n", err)
 	}
 	for _, user := range users {
+		var notes []string
 		if user.Name == s.cfg.CurrentUserName {
-			fmt.Printf("* %s (current)\n", user.Name)
+			notes = append(notes, "current")
+		}
+		if user.IsAdmin {
+			notes = append(notes, "admin")
+		}
+		if len(notes) > 0 {
+			fmt.Printf("* %s (%s)\n", user.Name, strings.Join(notes, ", "))
 		} else {
 			fmt.Printf("* %s\n", user.Name)
 		}
@@ -113,34 +155,64 @@ func getUsersHandler(s *state, cmd command) error {
 }
 
 func aggHandler(s *state, cmd command) error {
-	time_between_reqs := cmd.arg[0]
-	timeBetweenRequests, err := time.ParseDuration(time_between_reqs)
+	fs := flag.NewFlagSet("agg", flag.ContinueOnError)
+	grace := fs.Duration("grace", 30*time.Second, "how long to let feeds in progress finish after a shutdown signal")
+	metricsAddr := fs.String("metrics-addr", "", "serve Prometheus metrics on this address, e.g. :9090")
+	args, err := parseArgs(fs, cmd.arg)
+	if err != nil {
+		return err
+	}
+	if len(args) < 1 {
+		return errors.New("agg expects one argument: the time between requests, e.g. 1m")
+	}
+	timeBetweenRequests, err := time.ParseDuration(args[0])
 	if err != nil {
 		return fmt.Errorf("error parsing aggregation interval: %v", err)
 	}
 
-	ticker := time.NewTicker(timeBetweenRequests)
-	for ; ; <-ticker.C {
-		if err := scrapeFeeds(s); err != nil {
-			return fmt.Errorf("error aggregating feeds: %v", err)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if *metricsAddr != "" {
+		server, err := startMetricsServer(*metricsAddr)
+		if err != nil {
+			return err
 		}
+		defer server.Close()
 	}
 
+	slog.Info("collecting feeds", "interval", timeBetweenRequests)
+	sched := newScheduler(s, timeBetweenRequests, *grace)
+	err = sched.run(ctx, stop)
+	sched.logSummary()
+	return err
 }
 
 func handlerAddFeed(s *state, cmd command, user database.User) error {
-	if len(cmd.arg) < 2 {
+	fs := flag.NewFlagSet("addfeed", flag.ContinueOnError)
+	visibility := fs.String("visibility", visibilityPublic, "who can see and follow the feed: public, private or shared")
+	args, err := parseArgs(fs, cmd.arg)
+	if err != nil {
+		return err
+	}
+	if len(args) < 2 {
 		return fmt.Errorf("command expects two arguments: name and url")
 	}
+	if err := checkVisibility(*visibility); err != nil {
+		return err
+	}
 	feed, err := s.db.CreateFeed(context.Background(), database.CreateFeedParams{
-		ID:        uuid.New(),
-		CreatedAt: time.Now().UTC(),
-		UpdatedAt: time.Now().UTC(),
-		Name:      cmd.arg[0],
-		Url:       cmd.arg[1],
-		UserID:    user.ID,
+		ID:         uuid.New(),
+		CreatedAt:  time.Now().UTC(),
+		UpdatedAt:  time.Now().UTC(),
+		Name:       args[0],
+		Url:        args[1],
+		UserID:     user.ID,
+		Visibility: *visibility,
 	})
-	if err != nil {
+	if isUniqueViolation(err) {
+		return fmt.Errorf("a feed with url %s already exists", args[1])
+	} else if err != nil {
 		return fmt.Errorf("Error creating feed: %v", err)
 	}
 	cmd = command{name: "follow", arg: []string{feed.Url}}
@@ -151,14 +223,17 @@ func handlerAddFeed(s *state, cmd command, user database.User) error {
 	return nil
 }
 
-func feedsHandler(s *state, cmd command) error {
-	feeds, err := s.db.GetFeeds(context.Background())
+func feedsHandler(s *state, cmd command, user database.User) error {
+	feeds, err := s.db.GetFeeds(context.Background(), user.ID)
 	if err != nil {
 		return fmt.Errorf("error retrieving feeds: %v", err)
 	}
 	for _, feed := range feeds {
 
 		fmt.Printf("Feed Name: %v\nFeed URL: %v\nCreator: %v\n", feed.FeedName, feed.Url, feed.Creator)
+		if feed.Visibility != visibilityPublic {
+			fmt.Printf("Visibility: %v\n", feed.Visibility)
+		}
 	}
 	return nil
 }
@@ -167,9 +242,9 @@ func followHandler(s *state, cmd command, user database.User) error {
 	if len(cmd.arg) < 1 {
 		return fmt.Errorf("follow command requires one argument: url")
 	}
-	feed, err := s.db.GetFeedByURL(context.Background(), cmd.arg[0])
+	feed, err := visibleFeed(context.Background(), s, user, cmd.arg[0])
 	if err != nil {
-		return fmt.Errorf("error retrieving feed with url: %v", err)
+		return err
 	}
 	feed_follow, err := s.db.CreateFeedFollow(context.Background(), database.CreateFeedFollowParams{
 		ID:        uuid.New(),
@@ -191,9 +266,43 @@ func followingHandler(s *state, cmd command, user database.User) error {
 	if err != nil {
 		return fmt.Errorf("error retrieving feed follows for user %s: %v", user.Name, err)
 	}
+	tags, err := followTags(context.Background(), s, user.ID)
+	if err != nil {
+		return fmt.Errorf("error retrieving tags for user %s: %v", user.Name, err)
+	}
+
+	// group the follows by tag; a feed with several tags is listed under each
+	groups := map[string][]database.GetFeedFollowsForUserRow{}
+	var untagged []database.GetFeedFollowsForUserRow
 	for _, feed_follow := range feed_follows {
-		fmt.Println(feed_follow.Follower)
-		fmt.Println(feed_follow.Feed, "\n")
+		if len(tags[feed_follow.ID]) == 0 {
+			untagged = append(untagged, feed_follow)
+		}
+		for _, tag := range tags[feed_follow.ID] {
+			groups[tag] = append(groups[tag], feed_follow)
+		}
+	}
+	names := make([]string, 0, len(groups))
+	for tag := range groups {
+		names = append(names, tag)
+	}
+	sort.Strings(names)
+
+	fmt.Printf("Feeds followed by %s:\n", user.Name)
+	for _, tag := range names {
+		fmt.Printf("%s\n", tag)
+		for _, feed_follow := range groups[tag] {
+			fmt.Printf("  * %s (%s)%s\n", feed_follow.Feed, feed_follow.Url, followFlags(feed_follow))
+		}
+	}
+	if len(untagged) > 0 && len(names) > 0 {
+		fmt.Println("Untagged")
+	}
+	for _, feed_follow := range untagged {
+		if len(names) > 0 {
+			fmt.Print("  ")
+		}
+		fmt.Printf("* %s (%s)%s\n", feed_follow.Feed, feed_follow.Url, followFlags(feed_follow))
 	}
 	return nil
 }
@@ -202,10 +311,9 @@ func handlerUnfollow(s *state, cmd command, user database.User) error {
 	if len(cmd.arg) < 1 {
 		return fmt.Errorf("follow command requires one argument: url")
 	}
-	url := cmd.arg[0]
-	feed, err := s.db.GetFeedByURL(context.Background(), url)
+	feed, err := visibleFeed(context.Background(), s, user, cmd.arg[0])
 	if err != nil {
-		return fmt.Errorf("error retrieving feed with url %s: %v", url, err)
+		return err
 	}
 
 	err = s.db.DeleteFeedFollow(context.Background(), database.DeleteFeedFollowParams{
@@ -219,24 +327,164 @@ func handlerUnfollow(s *state, cmd command, user database.User) error {
 }
 
 func handlerBrowse(s *state, cmd command, user database.User) error {
+	fs := flag.NewFlagSet("browse", flag.ContinueOnError)
+	author := fs.String("author", "", "only show posts whose author contains this text")
+	category := fs.String("category", "", "only show posts with this category")
+	tag := fs.String("tag", "", "only show posts from feeds with this tag, or that a rule tagged")
+	starred := fs.Bool("starred", false, "only show starred posts")
+	markRead := fs.Bool("mark-read", false, "count the posts shown as seen, so the next digest leaves them out")
+	args, err := parseArgs(fs, cmd.arg)
+	if err != nil {
+		return err
+	}
 	limit := 2
-	var err error
-	if len(cmd.arg) == 1 {
-		if limit, err = strconv.Atoi(cmd.arg[0]); err != nil {
+	if len(args) == 1 {
+		if limit, err = strconv.Atoi(args[0]); err != nil {
 			return fmt.Errorf("error converting limit argument: %v", err)
 		}
 	}
-	posts, err := s.db.GetPostsForUser(context.Background(), database.GetPostsForUserParams{
-		UserID: user.ID,
-		Limit:  int32(limit),
-	})
+	rules, err := userRules(context.Background(), s, user.ID)
 	if err != nil {
-		return fmt.Errorf("error retrieving posts for user %v", user.Name)
+		return fmt.Errorf("error retrieving rules for user %v: %v", user.Name, err)
 	}
+
+	// rules may hide posts that were stored before the rule was added, so
+	// keep paging until there are enough posts left to show. Browse only
+	// applies rules for display, so posts hidden here are still in the next
+	// page's query and count towards the offset.
+	shown, offset := 0, 0
+	for shown < limit {
+		posts, err := s.db.GetPostsForUser(context.Background(), database.GetPostsForUserParams{
+			UserID:      user.ID,
+			StarredOnly: *starred,
+			Tag:         normalizeTag(*tag),
+			Author:      *author,
+			Category:    *category,
+			Limit:       int32(limit),
+			Offset:      int32(offset),
+		})
+		if err != nil {
+			return fmt.Errorf("error retrieving posts for user %v", user.Name)
+		}
+		var page []database.GetPostsForUserRow
+		seen := 0
+		for _, post := range posts {
+			if shown+len(page) == limit {
+				break
+			}
+			seen++
+			outcomes := evaluateRules(rules, post.FeedID, post.Title)
+			if outcomes[user.ID].hide {
+				continue
+			}
+			post.Starred = post.Starred || outcomes[user.ID].star
+			page = append(page, post)
+		}
+		details, err := loadPostDetails(context.Background(), s, user.ID, page)
+		if err != nil {
+			return err
+		}
+		for _, post := range page {
+			printPost(post, details)
+		}
+		if *markRead {
+			for _, post := range page {
+				err := s.db.MarkPostRead(context.Background(), database.MarkPostReadParams{
+					UserID: user.ID,
+					PostID: post.ID,
+					ReadAt: time.Now().UTC(),
+				})
+				if err != nil {
+					return fmt.Errorf("error marking post %v as read: %v", post.Title, err)
+				}
+			}
+		}
+		offset += seen
+		shown += len(page)
+		if len(posts) < limit {
+			break
+		}
+	}
+	return nil
+}
+
+// postDetails holds the categories, enclosures and tags of a page of
+// posts, loaded with one query each rather than per post.
+type postDetails struct {
+	categories map[uuid.UUID][]string
+	enclosures map[uuid.UUID][]database.PostEnclosure
+	tags       map[uuid.UUID][]string
+}
+
+func loadPostDetails(ctx context.Context, s *state, userID uuid.UUID, posts []database.GetPostsForUserRow) (postDetails, error) {
+	details := postDetails{
+		categories: map[uuid.UUID][]string{},
+		enclosures: map[uuid.UUID][]database.PostEnclosure{},
+		tags:       map[uuid.UUID][]string{},
+	}
+	if len(posts) == 0 {
+		return details, nil
+	}
+	ids := make([]uuid.UUID, 0, len(posts))
 	for _, post := range posts {
+		ids = append(ids, post.ID)
+	}
+	categories, err := s.db.GetCategoriesForPosts(ctx, ids)
+	if err != nil {
+		return postDetails{}, fmt.Errorf("error retrieving post categories: %v", err)
+	}
+	for _, category := range categories {
+		details.categories[category.PostID] = append(details.categories[category.PostID], category.Name)
+	}
+	enclosures, err := s.db.GetEnclosuresForPosts(ctx, ids)
+	if err != nil {
+		return postDetails{}, fmt.Errorf("error retrieving post enclosures: %v", err)
+	}
+	for _, enclosure := range enclosures {
+		details.enclosures[enclosure.PostID] = append(details.enclosures[enclosure.PostID], enclosure)
+	}
+	tags, err := s.db.GetPostTagsForPosts(ctx, database.GetPostTagsForPostsParams{UserID: userID, PostIds: ids})
+	if err != nil {
+		return postDetails{}, fmt.Errorf("error retrieving post tags: %v", err)
+	}
+	for _, tag := range tags {
+		details.tags[tag.PostID] = append(details.tags[tag.PostID], tag.Tag)
+	}
+	return details, nil
+}
+
+func printPost(post database.GetPostsForUserRow, details postDetails) {
+	categories := details.categories[post.ID]
+	enclosures := details.enclosures[post.ID]
+	tags := details.tags[post.ID]
+	fmt.Println("Post ID:", post.ID)
+	fmt.Println("Feed:", post.FeedName)
+	if post.Starred {
+		fmt.Println("Post Title:", post.Title, "★")
+	} else {
 		fmt.Println("Post Title:", post.Title)
-		fmt.Println("Post Description:", post.Description)
-		fmt.Println("")
 	}
-	return nil
+	if post.Author.Valid {
+		fmt.Println("Post Author:", post.Author.String)
+	}
+	if len(categories) > 0 {
+		fmt.Println("Post Categories:", strings.Join(categories, ", "))
+	}
+	if len(tags) > 0 {
+		fmt.Println("Tags:", strings.Join(tags, ", "))
+	}
+	if post.Episode.Valid {
+		fmt.Println("Episode:", post.Episode.String)
+	}
+	if post.Duration.Valid {
+		fmt.Println("Duration:", post.Duration.String)
+	}
+	if post.ImageUrl.Valid {
+		fmt.Println("Image:", post.ImageUrl.String)
+	}
+	for _, enclosure := range enclosures {
+		fmt.Printf("Enclosure: %s (%s)\n", enclosure.Url, enclosureSize(enclosure))
+	}
+	fmt.Println("Post Description:", post.Description.String)
+	fmt.Println("")
 }
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	pidFileName    = "gator.pid"
+	socketFileName = "gator.sock"
+	logFileName    = "gator.log"
+)
+
+// daemonStatus is what the control socket reports to "gator status".
+type daemonStatus struct {
+	PID        int       `json:"pid"`
+	StartedAt  time.Time `json:"started_at"`
+	LastTick   time.Time `json:"last_tick"`
+	Interval   string    `json:"interval"`
+	QueueDepth int64     `json:"queue_depth"`
+	Passes     int       `json:"passes"`
+	Fetched    int       `json:"fetched"`
+	NewPosts   int       `json:"new_posts"`
+	Failures   int       `json:"failures"`
+	Feeds      []feedRun `json:"feeds"`
+}
+
+func runtimePath(s *state, name string) (string, error) {
+	dir, err := s.cfg.RuntimeDirPath()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// handlerDaemonRun runs the scheduler in the foreground with a control
+// socket, which is what a systemd unit should start. "daemon start" runs it
+// in the background instead.
+func handlerDaemonRun(s *state, cmd command) error {
+	fs := flag.NewFlagSet("daemon run", flag.ContinueOnError)
+	grace := fs.Duration("grace", 30*time.Second, "how long to let feeds in progress finish after a shutdown signal")
+	metricsAddr := fs.String("metrics-addr", "", "serve Prometheus metrics on this address, e.g. :9090")
+	args, err := parseArgs(fs, cmd.arg)
+	if err != nil {
+		return err
+	}
+	if len(args) < 1 {
+		return errors.New("daemon run expects one argument: the time between requests, e.g. 1m")
+	}
+	interval, err := time.ParseDuration(args[0])
+	if err != nil {
+		return fmt.Errorf("error parsing aggregation interval: %v", err)
+	}
+
+	pidPath, err := runtimePath(s, pidFileName)
+	if err != nil {
+		return err
+	}
+	if err := writePidFile(pidPath); err != nil {
+		return err
+	}
+	defer os.Remove(pidPath)
+
+	socketPath, err := runtimePath(s, socketFileName)
+	if err != nil {
+		return err
+	}
+	// a socket left behind by a daemon that crashed would make Listen fail
+	os.Remove(socketPath)
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return fmt.Errorf("error opening control socket: %v", err)
+	}
+	defer os.Remove(socketPath)
+	if err := os.Chmod(socketPath, 0600); err != nil {
+		return err
+	}
+
+	if *metricsAddr != "" {
+		metricsServer, err := startMetricsServer(*metricsAddr)
+		if err != nil {
+			return err
+		}
+		defer metricsServer.Close()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	sched := newScheduler(s, interval, *grace)
+
+	server := &http.Server{Handler: controlHandler(s, sched)}
+	go server.Serve(listener)
+	defer server.Close()
+
+	// systemd's ExecReload conventionally sends SIGHUP
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	defer signal.Stop(hangups)
+	go func() {
+		for range hangups {
+			if err := sched.reload(ctx); err != nil {
+				slog.Error("error reloading configuration", "err", err)
+			}
+		}
+	}()
+
+	slog.Info("daemon running", "pid", os.Getpid(), "interval", interval, "socket", socketPath)
+	err = sched.run(ctx, stop)
+	sched.logSummary()
+	return err
+}
+
+func controlHandler(s *state, sched *scheduler) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		status := sched.status()
+		depth, err := s.db.CountFeedsDue(r.Context(), time.Now().UTC())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		status.QueueDepth = depth
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(status)
+	})
+	mux.HandleFunc("POST /reload", func(w http.ResponseWriter, r *http.Request) {
+		if err := sched.reload(r.Context()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return mux
+}
+
+func (sc *scheduler) status() daemonStatus {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	status := daemonStatus{
+		PID:       os.Getpid(),
+		StartedAt: sc.startedAt,
+		LastTick:  sc.lastTick,
+		Interval:  sc.interval.String(),
+		Passes:    sc.passes,
+		Fetched:   sc.total.feeds,
+		NewPosts:  sc.total.newPosts,
+		Failures:  sc.total.failures,
+	}
+	for _, run := range sc.lastRuns {
+		status.Feeds = append(status.Feeds, run)
+	}
+	sort.Slice(status.Feeds, func(i, j int) bool {
+		return status.Feeds[i].Feed < status.Feeds[j].Feed
+	})
+	return status
+}
+
+// controlClient talks HTTP to the daemon over its unix socket.
+func controlClient(s *state) (*http.Client, error) {
+	socketPath, err := runtimePath(s, socketFileName)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(socketPath); err != nil {
+		return nil, errors.New("the gator daemon is not running")
+	}
+	return &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socketPath)
+			},
+		},
+	}, nil
+}
+
+func statusHandler(s *state, cmd command) error {
+	client, err := controlClient(s)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Get("http://gator/status")
+	if err != nil {
+		return fmt.Errorf("error contacting the gator daemon: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("daemon returned %s", resp.Status)
+	}
+	status := daemonStatus{}
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return fmt.Errorf("error reading daemon status: %v", err)
+	}
+
+	fmt.Printf("PID: %d\n", status.PID)
+	fmt.Printf("Uptime: %s\n", time.Since(status.StartedAt).Round(time.Second))
+	fmt.Printf("Interval: %s\n", status.Interval)
+	fmt.Printf("Last tick: %s (%s ago)\n", status.LastTick.Format(time.RFC1123), time.Since(status.LastTick).Round(time.Second))
+	fmt.Printf("Queue depth: %d feeds due\n", status.QueueDepth)
+	fmt.Printf("Totals: %d passes, %d feeds fetched, %d new posts, %d failed fetches\n",
+		status.Passes, status.Fetched, status.NewPosts, status.Failures)
+	if len(status.Feeds) > 0 {
+		fmt.Println()
+	}
+	for _, run := range status.Feeds {
+		result := fmt.Sprintf("%d new posts", run.NewPosts)
+		if run.Error != "" {
+			result = "failed: " + run.Error
+		}
+		fmt.Printf("%s (%s)\n  %s at %s\n", run.Feed, run.URL, result, run.At.Local().Format(time.RFC1123))
+	}
+	return nil
+}
+
+func handlerDaemonReload(s *state, cmd command) error {
+	client, err := controlClient(s)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Post("http://gator/reload", "", nil)
+	if err != nil {
+		return fmt.Errorf("error contacting the gator daemon: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("daemon returned %s", resp.Status)
+	}
+	fmt.Println("Configuration reloaded")
+	return nil
+}
+
+// handlerDaemonStart re-runs gator as "daemon run" in a new session, with
+// its output going to a log file, and returns straight away.
+func handlerDaemonStart(s *state, cmd command) error {
+	if len(cmd.arg) < 1 {
+		return errors.New("daemon start expects one argument: the time between requests, e.g. 1m")
+	}
+	pidPath, err := runtimePath(s, pidFileName)
+	if err != nil {
+		return err
+	}
+	if pid, running := readPidFile(pidPath); running {
+		return fmt.Errorf("the gator daemon is already running (pid %d)", pid)
+	}
+	logPath, err := runtimePath(s, logFileName)
+	if err != nil {
+		return err
+	}
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	// name the profile even if it was picked by "profile use", so that
+	// switching profiles later doesn't switch the daemon's
+	args := append(s.globalArgs[:len(s.globalArgs):len(s.globalArgs)], "--profile", s.cfg.Profile(), "daemon", "run")
+	args = append(args, cmd.arg...)
+	child := exec.Command(executable, args...)
+	child.Stdout = logFile
+	child.Stderr = logFile
+	detach(child)
+	if err := child.Start(); err != nil {
+		return fmt.Errorf("error starting the gator daemon: %v", err)
+	}
+	fmt.Printf("gator daemon started (pid %d), logging to %s\n", child.Process.Pid, logPath)
+	return child.Process.Release()
+}
+
+func handlerDaemonStop(s *state, cmd command) error {
+	pidPath, err := runtimePath(s, pidFileName)
+	if err != nil {
+		return err
+	}
+	pid, running := readPidFile(pidPath)
+	if !running {
+		return errors.New("the gator daemon is not running")
+	}
+	if err := terminateProcess(pid); err != nil {
+		return fmt.Errorf("error stopping the gator daemon: %v", err)
+	}
+	fmt.Printf("Sent stop signal to the gator daemon (pid %d)\n", pid)
+	return nil
+}
+
+// writePidFile records our pid, refusing to start if another daemon that
+// is still alive already did.
+func writePidFile(path string) error {
+	if pid, running := readPidFile(path); running {
+		return fmt.Errorf("the gator daemon is already running (pid %d)", pid)
+	}
+	return os.WriteFile(path, []byte(strconv.Itoa(os.Getpid())+"\n"), 0600)
+}
+
+func readPidFile(path string) (int, bool) {
+	dat, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(dat)))
+	if err != nil || pid <= 0 {
+		return 0, false
+	}
+	return pid, processAlive(pid)
+}
@@ -0,0 +1,20 @@
+//go:build !unix
+
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+func detach(cmd *exec.Cmd) {}
+
+func processAlive(pid int) bool {
+	_, err := os.FindProcess(pid)
+	return err == nil
+}
+
+func terminateProcess(pid int) error {
+	return errors.New("stopping the daemon is only supported on unix systems")
+}
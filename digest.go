@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	"flag"
+	"fmt"
+	"html"
+	htmltemplate "html/template"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/Lanrey-waju/gator.git/internal/config"
+	"github.com/Lanrey-waju/gator.git/internal/database"
+	"github.com/google/uuid"
+)
+
+//go:embed templates/digest.*.tmpl
+var digestTemplates embed.FS
+
+// digestSummaryLength caps the description shown under each post, in runes.
+const digestSummaryLength = 280
+
+type digest struct {
+	User  string
+	Since time.Time
+	Total int
+	Feeds []digestFeed
+}
+
+type digestFeed struct {
+	Name  string
+	URL   string
+	Posts []digestPost
+}
+
+type digestPost struct {
+	Title   string
+	URL     string
+	Author  string
+	Summary string
+}
+
+// digestFormats maps --format to its template file and the MIME type used
+// when the digest is mailed.
+var digestFormats = map[string]struct {
+	file        string
+	contentType string
+}{
+	"text":     {"templates/digest.txt.tmpl", "text/plain"},
+	"markdown": {"templates/digest.md.tmpl", "text/markdown"},
+	"html":     {"templates/digest.html.tmpl", "text/html"},
+}
+
+var digestFuncs = map[string]any{
+	"date": func(t time.Time) string { return t.Local().Format(time.RFC1123) },
+	"md":   escapeMarkdown,
+}
+
+func handlerDigest(s *state, cmd command, user database.User) error {
+	fs := flag.NewFlagSet("digest", flag.ContinueOnError)
+	since := fs.Duration("since", 24*time.Hour, "include posts stored within this long")
+	format := fs.String("format", "text", "output format: text, markdown or html")
+	send := fs.Bool("send", false, "email the digest through the smtp server in the config file")
+	to := fs.String("to", "", "recipient for --send; defaults to smtp.to in the config file")
+	peek := fs.Bool("peek", false, "leave the posts unseen, so they appear in the next digest too")
+	if _, err := parseArgs(fs, cmd.arg); err != nil {
+		return err
+	}
+	spec, ok := digestFormats[*format]
+	if !ok {
+		return fmt.Errorf("unknown digest format %q: use text, markdown or html", *format)
+	}
+
+	d, postIDs, err := buildDigest(context.Background(), s, user, time.Now().Add(-*since))
+	if err != nil {
+		return err
+	}
+	if d.Total == 0 {
+		fmt.Printf("No unseen posts since %s\n", d.Since.Local().Format(time.RFC1123))
+		return nil
+	}
+	body, err := renderDigest(spec.file, d)
+	if err != nil {
+		return fmt.Errorf("error rendering digest: %v", err)
+	}
+
+	if *send {
+		if s.cfg.SMTP == nil {
+			return errors.New("digest --send needs an smtp section in the config file")
+		}
+		recipient := *to
+		if recipient == "" {
+			recipient = s.cfg.SMTP.To
+		}
+		if recipient == "" {
+			return errors.New("digest --send needs --to or smtp.to in the config file")
+		}
+		subject := fmt.Sprintf("gator digest: %d new %s", d.Total, pluralize(d.Total, "post", "posts"))
+		if err := sendMail(*s.cfg.SMTP, recipient, subject, spec.contentType, body); err != nil {
+			return fmt.Errorf("error sending digest: %v", err)
+		}
+		fmt.Printf("Sent a digest of %d posts to %s\n", d.Total, recipient)
+	} else {
+		fmt.Print(string(body))
+	}
+
+	if *peek {
+		return nil
+	}
+	for _, id := range postIDs {
+		err := s.db.MarkPostRead(context.Background(), database.MarkPostReadParams{
+			UserID: user.ID,
+			PostID: id,
+			ReadAt: time.Now().UTC(),
+		})
+		if err != nil {
+			return fmt.Errorf("error marking posts as seen: %v", err)
+		}
+	}
+	return nil
+}
+
+// buildDigest groups the posts user has not seen, stored since since, by
+// feed. It also returns their IDs so they can be marked as seen.
+func buildDigest(ctx context.Context, s *state, user database.User, since time.Time) (digest, []uuid.UUID, error) {
+	d := digest{User: user.Name, Since: since}
+	rows, err := s.db.GetUnreadPostsForUser(ctx, database.GetUnreadPostsForUserParams{
+		UserID: user.ID,
+		Since:  since.UTC(),
+	})
+	if err != nil {
+		return d, nil, fmt.Errorf("error retrieving posts for user %v: %v", user.Name, err)
+	}
+	var ids []uuid.UUID
+	for _, row := range rows {
+		// rows are ordered by feed, so a new feed starts a new group
+		if len(d.Feeds) == 0 || d.Feeds[len(d.Feeds)-1].URL != row.FeedUrl {
+			d.Feeds = append(d.Feeds, digestFeed{Name: row.FeedName, URL: row.FeedUrl})
+		}
+		feed := &d.Feeds[len(d.Feeds)-1]
+		feed.Posts = append(feed.Posts, digestPost{
+			Title:   row.Title,
+			URL:     row.Url,
+			Author:  row.Author.String,
+			Summary: summarize(row.Description.String, digestSummaryLength),
+		})
+		d.Total++
+		ids = append(ids, row.ID)
+	}
+	return d, ids, nil
+}
+
+func renderDigest(file string, d digest) ([]byte, error) {
+	var buf bytes.Buffer
+	if strings.HasSuffix(file, ".html.tmpl") {
+		tmpl, err := htmltemplate.New("").Funcs(digestFuncs).ParseFS(digestTemplates, file)
+		if err != nil {
+			return nil, err
+		}
+		err = tmpl.ExecuteTemplate(&buf, strings.TrimPrefix(file, "templates/"), d)
+		return buf.Bytes(), err
+	}
+	tmpl, err := template.New("").Funcs(digestFuncs).ParseFS(digestTemplates, file)
+	if err != nil {
+		return nil, err
+	}
+	err = tmpl.ExecuteTemplate(&buf, strings.TrimPrefix(file, "templates/"), d)
+	return buf.Bytes(), err
+}
+
+var (
+	htmlTags   = regexp.MustCompile(`<[^>]*>`)
+	whitespace = regexp.MustCompile(`\s+`)
+)
+
+// summarize turns an HTML description into a single line of plain text of
+// at most max runes.
+func summarize(description string, max int) string {
+	text := html.UnescapeString(htmlTags.ReplaceAllString(description, " "))
+	text = strings.TrimSpace(whitespace.ReplaceAllString(text, " "))
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+	return strings.TrimSpace(string(runes[:max-1])) + "…"
+}
+
+var markdownSpecial = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`,
+	"[", `\[`, "]", `\]`, "<", `\<`, ">", `\>`, "#", `\#`,
+)
+
+func escapeMarkdown(s string) string {
+	return markdownSpecial.Replace(s)
+}
+
+func pluralize(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
+
+// sendMail delivers body to recipient. net/smtp upgrades to TLS when the
+// server offers STARTTLS, and refuses to send a password over a plain
+// connection to anything but localhost.
+func sendMail(cfg config.SMTPConfig, recipient, subject, contentType string, body []byte) error {
+	if cfg.Host == "" || cfg.From == "" {
+		return errors.New("smtp.host and smtp.from must be set in the config file")
+	}
+	from, err := mailAddress("smtp.from", cfg.From)
+	if err != nil {
+		return err
+	}
+	to, err := mailAddress("recipient", recipient)
+	if err != nil {
+		return err
+	}
+	port := cfg.Port
+	if port == 0 {
+		port = 587
+	}
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		password, err := cfg.Password()
+		if err != nil {
+			return fmt.Errorf("error reading smtp password: %v", err)
+		}
+		auth = smtp.PlainAuth("", cfg.Username, password, cfg.Host)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: %s; charset=utf-8\r\n", contentType)
+	msg.WriteString("\r\n")
+	msg.Write(body)
+
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(port))
+	return smtp.SendMail(addr, auth, from.Address, []string{to.Address}, msg.Bytes())
+}
+
+// mailAddress parses an address that goes into a mail header. Line breaks
+// are refused outright so that a crafted --to or smtp.from cannot add
+// headers of its own.
+func mailAddress(field, value string) (*mail.Address, error) {
+	if strings.ContainsAny(value, "\r\n") {
+		return nil, fmt.Errorf("%s must not contain line breaks", field)
+	}
+	addr, err := mail.ParseAddress(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s address %q: %v", field, value, err)
+	}
+	return addr, nil
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"mime"
+	"net"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Lanrey-waju/gator.git/internal/config"
+)
+
+// smtpMessage is what the stand-in SMTP server received in one session.
+type smtpMessage struct {
+	auth string
+	from string
+	to   []string
+	data string
+}
+
+// startSMTP runs a minimal SMTP server on the loopback interface that
+// accepts a single session, advertising AUTH PLAIN but not STARTTLS, and
+// sends what it received on the returned channel.
+func startSMTP(t *testing.T) (host string, port int, received <-chan smtpMessage) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error listening: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	ch := make(chan smtpMessage, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+		var msg smtpMessage
+		reply("220 localhost ESMTP stand-in")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+			switch verb {
+			case "EHLO", "HELO":
+				reply("250-localhost")
+				reply("250 AUTH PLAIN")
+			case "AUTH":
+				msg.auth = strings.TrimPrefix(line, "AUTH PLAIN ")
+				reply("235 2.7.0 Authentication successful")
+			case "MAIL":
+				msg.from = line
+				reply("250 OK")
+			case "RCPT":
+				msg.to = append(msg.to, line)
+				reply("250 OK")
+			case "DATA":
+				reply("354 End data with <CR><LF>.<CR><LF>")
+				var data strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if l == ".\r\n" {
+						break
+					}
+					data.WriteString(l)
+				}
+				msg.data = data.String()
+				reply("250 OK")
+			case "QUIT":
+				reply("221 Bye")
+				ch <- msg
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+	addr := ln.Addr().(*net.TCPAddr)
+	return "127.0.0.1", addr.Port, ch
+}
+
+func TestSendMail(t *testing.T) {
+	host, port, received := startSMTP(t)
+	passwordFile := filepath.Join(t.TempDir(), "smtp-password")
+	if err := os.WriteFile(passwordFile, []byte("hunter2\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(config.SMTPPasswordEnv, "")
+	os.Unsetenv(config.SMTPPasswordEnv)
+	cfg := config.SMTPConfig{
+		Host:         host,
+		Port:         port,
+		Username:     "gator",
+		PasswordFile: passwordFile,
+		From:         "Gator <gator@example.com>",
+	}
+	body := []byte("Blog\n  Hello world https://example.com/hello\n")
+	err := sendMail(cfg, "me@example.com", "gator digest: 2 new posts — ünïcode", "text/plain", body)
+	if err != nil {
+		t.Fatalf("sendMail returned error: %v", err)
+	}
+	msg := <-received
+
+	creds, err := base64.StdEncoding.DecodeString(msg.auth)
+	if err != nil || string(creds) != "\x00gator\x00hunter2" {
+		t.Errorf("AUTH PLAIN credentials = %q, want the user and the password from the file", creds)
+	}
+	if msg.from != "MAIL FROM:<gator@example.com>" {
+		t.Errorf("envelope sender = %q", msg.from)
+	}
+	if len(msg.to) != 1 || msg.to[0] != "RCPT TO:<me@example.com>" {
+		t.Errorf("envelope recipients = %q", msg.to)
+	}
+
+	parsed, err := mail.ReadMessage(strings.NewReader(msg.data))
+	if err != nil {
+		t.Fatalf("error parsing the message: %v", err)
+	}
+	headers := map[string]string{
+		"From":         `"Gator" <gator@example.com>`,
+		"To":           "<me@example.com>",
+		"Mime-Version": "1.0",
+		"Content-Type": "text/plain; charset=utf-8",
+	}
+	for name, want := range headers {
+		if got := parsed.Header.Get(name); got != want {
+			t.Errorf("%s header = %q, want %q", name, got, want)
+		}
+	}
+	rawSubject := parsed.Header.Get("Subject")
+	if !strings.HasPrefix(rawSubject, "=?utf-8?q?") {
+		t.Errorf("Subject header %q is not Q-encoded", rawSubject)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(rawSubject)
+	if err != nil || subject != "gator digest: 2 new posts — ünïcode" {
+		t.Errorf("Subject decodes to %q (%v)", subject, err)
+	}
+	if _, err := mail.ParseDate(parsed.Header.Get("Date")); err != nil {
+		t.Errorf("Date header %q does not parse: %v", parsed.Header.Get("Date"), err)
+	}
+	gotBody := strings.ReplaceAll(msg.data[strings.Index(msg.data, "\r\n\r\n")+4:], "\r\n", "\n")
+	if gotBody != string(body) {
+		t.Errorf("body = %q, want %q", gotBody, body)
+	}
+}
+
+func TestSendMailPasswordFromEnvironment(t *testing.T) {
+	host, port, received := startSMTP(t)
+	t.Setenv(config.SMTPPasswordEnv, "from-env")
+	cfg := config.SMTPConfig{Host: host, Port: port, Username: "gator", From: "gator@example.com"}
+	if err := sendMail(cfg, "me@example.com", "digest", "text/plain", []byte("hi\n")); err != nil {
+		t.Fatalf("sendMail returned error: %v", err)
+	}
+	msg := <-received
+	creds, _ := base64.StdEncoding.DecodeString(msg.auth)
+	if string(creds) != "\x00gator\x00from-env" {
+		t.Errorf("AUTH PLAIN credentials = %q, want the password from %s", creds, config.SMTPPasswordEnv)
+	}
+}
+
+func TestSendMailRejectsHeaderInjection(t *testing.T) {
+	cfg := config.SMTPConfig{Host: "127.0.0.1", Port: 1, From: "gator@example.com"}
+	recipients := []string{
+		"me@example.com\r\nBcc: victim@example.com",
+		"me@example.com\nBcc: victim@example.com",
+		"not an address",
+		"",
+	}
+	for _, recipient := range recipients {
+		if err := sendMail(cfg, recipient, "digest", "text/plain", nil); err == nil {
+			t.Errorf("sendMail to %q succeeded, want an error", recipient)
+		}
+	}
+
+	cfg.From = "gator@example.com\r\nBcc: victim@example.com"
+	if err := sendMail(cfg, "me@example.com", "digest", "text/plain", nil); err == nil {
+		t.Error("sendMail with a From containing a line break succeeded, want an error")
+	}
+}
+
+func TestSMTPPasswordFile(t *testing.T) {
+	t.Setenv(config.SMTPPasswordEnv, "")
+	os.Unsetenv(config.SMTPPasswordEnv)
+	dir := t.TempDir()
+	for i, content := range []string{"secret", "secret\n", "secret\r\nignored\n"} {
+		path := filepath.Join(dir, strconv.Itoa(i))
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		got, err := config.SMTPConfig{PasswordFile: path}.Password()
+		if err != nil || got != "secret" {
+			t.Errorf("Password() from %q = %q, %v; want \"secret\"", content, got, err)
+		}
+	}
+	if _, err := (config.SMTPConfig{PasswordFile: filepath.Join(dir, "missing")}).Password(); err == nil {
+		t.Error("Password() with a missing file succeeded, want an error")
+	}
+}
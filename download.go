@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Lanrey-waju/gator.git/internal/database"
+	"github.com/google/uuid"
+)
+
+func downloadHandler(s *state, cmd command, user database.User) error {
+	fs := flag.NewFlagSet("download", flag.ContinueOnError)
+	dir := fs.String("dir", ".", "directory to save the enclosure in")
+	index := fs.Int("index", 1, "which of the post's enclosures to download, counting from 1")
+	all := fs.Bool("all", false, "download every enclosure of the post")
+	args, err := parseArgs(fs, cmd.arg)
+	if err != nil {
+		return err
+	}
+	if len(args) < 1 {
+		return errors.New("download command requires one argument: post id")
+	}
+	postID, err := uuid.Parse(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid post id %s: %v", args[0], err)
+	}
+	post, err := visiblePost(context.Background(), s, user, postID)
+	if err != nil {
+		return fmt.Errorf("error retrieving post %s: %v", postID, err)
+	}
+	enclosures, err := s.db.GetEnclosuresForPost(context.Background(), post.ID)
+	if err != nil {
+		return fmt.Errorf("error retrieving enclosures for post %s: %v", post.Title, err)
+	}
+	if len(enclosures) == 0 {
+		return fmt.Errorf("post %s has no enclosure to download", post.Title)
+	}
+	if !*all {
+		if *index < 1 || *index > len(enclosures) {
+			return fmt.Errorf("post %s has %d %s; --index must be between 1 and %d",
+				post.Title, len(enclosures), pluralize(len(enclosures), "enclosure", "enclosures"), len(enclosures))
+		}
+		if len(enclosures) > 1 {
+			fmt.Printf("Post %s has %d enclosures; downloading number %d (use --index or --all for the others)\n",
+				post.Title, len(enclosures), *index)
+		}
+		enclosures = enclosures[*index-1 : *index]
+	}
+
+	if err := os.MkdirAll(*dir, 0755); err != nil {
+		return fmt.Errorf("error creating download directory: %v", err)
+	}
+	for _, enclosure := range enclosures {
+		dest := filepath.Join(*dir, enclosureFileName(post, enclosure))
+		if _, err := os.Stat(dest); err == nil {
+			fmt.Printf("%s already downloaded to %s\n", enclosure.Url, dest)
+			continue
+		}
+		if err := downloadEnclosure(context.Background(), s.fetcher, enclosure.Url, dest); err != nil {
+			return fmt.Errorf("error downloading %s: %v", enclosure.Url, err)
+		}
+		fmt.Printf("Saved %s to %s\n", post.Title, dest)
+	}
+	return nil
+}
+
+// downloadEnclosure streams url into dest. Data is written to dest+".part"
+// first, and an existing partial file is resumed with a Range request, so an
+// interrupted download of a long episode does not start over. A partial file
+// the server's answer does not line up with is discarded and the download
+// starts again from the beginning.
+func downloadEnclosure(ctx context.Context, f *fetcher, rawURL, dest string) error {
+	partial := dest + ".part"
+	var offset int64
+	if info, err := os.Stat(partial); err == nil {
+		offset = info.Size()
+	}
+
+	req, err := f.newRequest(ctx, rawURL)
+	if err != nil {
+		return err
+	}
+	if offset > 0 {
+		req.Header.Add("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := f.doStreaming(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		start, _, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		if offset == 0 || !ok || start != offset {
+			return restartDownload(ctx, f, rawURL, dest, offset)
+		}
+		flags |= os.O_APPEND
+		fmt.Printf("Resuming download at %s\n", formatBytes(offset))
+	case http.StatusOK:
+		// the server ignored the range, so start from scratch
+		flags |= os.O_TRUNC
+		offset = 0
+	case http.StatusRequestedRangeNotSatisfiable:
+		// the range starts at the end of the enclosure, so the partial file
+		// holds all of it, but only if the sizes agree
+		_, total, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		if offset > 0 && ok && total == offset {
+			return os.Rename(partial, dest)
+		}
+		return restartDownload(ctx, f, rawURL, dest, offset)
+	default:
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	out, err := os.OpenFile(partial, flags, 0644)
+	if err != nil {
+		return err
+	}
+	total := int64(-1)
+	if resp.ContentLength >= 0 {
+		total = offset + resp.ContentLength
+	}
+	progress := &progressWriter{written: offset, total: total}
+	_, err = io.Copy(out, io.TeeReader(resp.Body, progress))
+	fmt.Println()
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(partial, dest)
+}
+
+// restartDownload throws away the partial file of dest and downloads it
+// again from the start. offset is the size of the discarded file; if it was
+// already zero there is nothing to restart, and the server's answer was
+// simply wrong.
+func restartDownload(ctx context.Context, f *fetcher, rawURL, dest string, offset int64) error {
+	if offset == 0 {
+		return errors.New("the server sent a range that was not asked for")
+	}
+	fmt.Printf("Discarding the partial download of %s and starting over\n", formatBytes(offset))
+	if err := os.Remove(dest + ".part"); err != nil {
+		return err
+	}
+	return downloadEnclosure(ctx, f, rawURL, dest)
+}
+
+// parseContentRange reads a Content-Range header, "bytes start-end/total"
+// or "bytes */total", returning the first byte it covers, or -1 for the
+// second form, and the total size, or -1 if the server does not know it.
+func parseContentRange(header string) (start, total int64, ok bool) {
+	spec, found := strings.CutPrefix(strings.TrimSpace(header), "bytes ")
+	if !found {
+		return 0, 0, false
+	}
+	byteRange, size, found := strings.Cut(spec, "/")
+	if !found {
+		return 0, 0, false
+	}
+	total = -1
+	if size != "*" {
+		n, err := strconv.ParseInt(size, 10, 64)
+		if err != nil || n < 0 {
+			return 0, 0, false
+		}
+		total = n
+	}
+	if byteRange == "*" {
+		return -1, total, total >= 0
+	}
+	first, last, found := strings.Cut(byteRange, "-")
+	if !found {
+		return 0, 0, false
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, false
+	}
+	end, err := strconv.ParseInt(last, 10, 64)
+	if err != nil || end < start || (total >= 0 && end >= total) {
+		return 0, 0, false
+	}
+	return start, total, true
+}
+
+// enclosureFileName names the download after the last path segment of the
+// enclosure URL, falling back to the post id when the URL has none.
+func enclosureFileName(post database.Post, enclosure database.PostEnclosure) string {
+	name := ""
+	if u, err := url.Parse(enclosure.Url); err == nil {
+		name = path.Base(u.Path)
+	}
+	// "." and ".." would name the download directory or its parent
+	if name == "" || name == "." || name == ".." || name == "/" {
+		name = post.ID.String()
+		if exts, err := mime.ExtensionsByType(enclosure.MimeType.String); err == nil && len(exts) > 0 {
+			name += exts[0]
+		}
+	}
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == 0 {
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+type progressWriter struct {
+	written int64
+	total   int64
+	printed int64
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	p.written += int64(len(b))
+	// redraw at most every 256 KiB to keep the terminal readable
+	if p.written-p.printed < 256*1024 && p.written != p.total {
+		return len(b), nil
+	}
+	p.printed = p.written
+	if p.total > 0 {
+		fmt.Printf("\r%s / %s (%d%%)", formatBytes(p.written), formatBytes(p.total), p.written*100/p.total)
+	} else {
+		fmt.Printf("\r%s", formatBytes(p.written))
+	}
+	return len(b), nil
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func enclosureSize(enclosure database.PostEnclosure) string {
+	parts := []string{}
+	if enclosure.MimeType.Valid {
+		parts = append(parts, enclosure.MimeType.String)
+	}
+	if enclosure.Length.Valid {
+		parts = append(parts, formatBytes(enclosure.Length.Int64))
+	}
+	if len(parts) == 0 {
+		return "unknown size"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// visiblePost looks up a post, reporting posts in feeds user cannot see as
+// not found, like visibleFeed does for feeds.
+func visiblePost(ctx context.Context, s *state, user database.User, postID uuid.UUID) (database.Post, error) {
+	post, err := s.db.GetPostByID(ctx, postID)
+	if err != nil {
+		return database.Post{}, err
+	}
+	feed, err := s.db.GetFeedByID(ctx, post.FeedID)
+	if err != nil {
+		return database.Post{}, err
+	}
+	if feed.Visibility != visibilityPublic && feed.UserID != user.ID {
+		shared, err := sharedFeeds(ctx, s, user.ID)
+		if err != nil {
+			return database.Post{}, err
+		}
+		if !feedVisibleTo(feed, user, shared) {
+			return database.Post{}, sql.ErrNoRows
+		}
+	}
+	return post, nil
+}
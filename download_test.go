@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Lanrey-waju/gator.git/internal/config"
+)
+
+// testFetcher returns a fetcher whose rate limits don't slow tests down.
+func testFetcher() *fetcher {
+	return newFetcher(config.HTTPConfig{
+		HostInterval: config.Duration{Duration: time.Millisecond},
+		HostBurst:    100,
+		MinSpacing:   config.Duration{Duration: time.Millisecond},
+	})
+}
+
+func TestParseContentRange(t *testing.T) {
+	tests := []struct {
+		header    string
+		wantStart int64
+		wantTotal int64
+		wantOK    bool
+	}{
+		{"bytes 0-99/100", 0, 100, true},
+		{"bytes 10-99/100", 10, 100, true},
+		{"bytes 10-99/*", 10, -1, true},
+		{"bytes */100", -1, 100, true},
+		{"bytes */*", 0, 0, false},
+		{"bytes 10-5/100", 0, 0, false},
+		{"bytes 10-100/100", 0, 0, false},
+		{"bytes -5-10/100", 0, 0, false},
+		{"bytes 10-99", 0, 0, false},
+		{"items 0-9/10", 0, 0, false},
+		{"", 0, 0, false},
+	}
+	for _, tt := range tests {
+		start, total, ok := parseContentRange(tt.header)
+		if ok != tt.wantOK || (ok && (start != tt.wantStart || total != tt.wantTotal)) {
+			t.Errorf("parseContentRange(%q) = %d, %d, %t; want %d, %d, %t",
+				tt.header, start, total, ok, tt.wantStart, tt.wantTotal, tt.wantOK)
+		}
+	}
+}
+
+func TestDownloadEnclosure(t *testing.T) {
+	content := []byte(strings.Repeat("0123456789", 50))
+	serveContent := func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "episode.mp3", time.Time{}, bytes.NewReader(content))
+	}
+	tests := []struct {
+		name    string
+		partial []byte
+		handler http.HandlerFunc
+	}{
+		{"fresh download", nil, serveContent},
+		{"resumes a partial file", content[:120], serveContent},
+		{"partial file already complete", content, serveContent},
+		{
+			"server ignores the range",
+			[]byte("garbage that will be replaced"),
+			func(w http.ResponseWriter, r *http.Request) { w.Write(content) },
+		},
+		{
+			"206 from the wrong offset restarts",
+			[]byte("stale bytes"),
+			func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Range") == "" {
+					w.Write(content)
+					return
+				}
+				w.Header().Set("Content-Range", fmt.Sprintf("bytes 0-%d/%d", len(content)-1, len(content)))
+				w.WriteHeader(http.StatusPartialContent)
+				w.Write(content)
+			},
+		},
+		{
+			"416 for a truncated partial file restarts",
+			content[:40],
+			func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Range") == "" {
+					w.Write(content)
+					return
+				}
+				w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", len(content)))
+				w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(tt.handler)
+			defer srv.Close()
+			dest := filepath.Join(t.TempDir(), "episode.mp3")
+			if tt.partial != nil {
+				if err := os.WriteFile(dest+".part", tt.partial, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := downloadEnclosure(context.Background(), testFetcher(), srv.URL, dest); err != nil {
+				t.Fatalf("downloadEnclosure returned error: %v", err)
+			}
+			got, err := os.ReadFile(dest)
+			if err != nil {
+				t.Fatalf("error reading the download: %v", err)
+			}
+			if !bytes.Equal(got, content) {
+				t.Errorf("downloaded %d bytes %q, want the %d byte enclosure", len(got), got, len(content))
+			}
+			if _, err := os.Stat(dest + ".part"); !os.IsNotExist(err) {
+				t.Errorf("partial file left behind: %v", err)
+			}
+		})
+	}
+}
+
+func TestDownloadEnclosureUnaskedRange(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Range", "bytes */10")
+		w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+	}))
+	defer srv.Close()
+	dest := filepath.Join(t.TempDir(), "episode.mp3")
+	err := downloadEnclosure(context.Background(), testFetcher(), srv.URL, dest)
+	if err == nil || os.IsNotExist(err) {
+		t.Errorf("downloadEnclosure = %v, want an error about the unexpected range", err)
+	}
+	if _, err := os.Stat(dest); !os.IsNotExist(err) {
+		t.Errorf("a download was saved despite the error")
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Lanrey-waju/gator.git/internal/database"
+	"github.com/Lanrey-waju/gator.git/internal/secrets"
+	"github.com/google/uuid"
+)
+
+// feedCredentials are sent with every request for a feed. They are stored
+// encrypted in feed_credentials rather than alongside the feed itself.
+type feedCredentials struct {
+	Username string            `json:"username,omitempty"`
+	Password string            `json:"password,omitempty"`
+	Token    string            `json:"token,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+}
+
+func (c feedCredentials) apply(req *http.Request) {
+	for name, value := range c.Headers {
+		req.Header.Set(name, value)
+	}
+	if c.Username != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+}
+
+// feedCredentialsAAD binds sealed credentials to their feed.
+func feedCredentialsAAD(feedID uuid.UUID) []byte {
+	return []byte("feed_credentials:" + feedID.String())
+}
+
+func loadFeedCredentials(ctx context.Context, s *state, feedID uuid.UUID) (feedCredentials, error) {
+	sealed, err := s.db.GetFeedCredentials(ctx, feedID)
+	if err == sql.ErrNoRows {
+		return feedCredentials{}, nil
+	} else if err != nil {
+		return feedCredentials{}, err
+	}
+	dat, err := openSecret(s, sealed, feedCredentialsAAD(feedID))
+	if err != nil {
+		return feedCredentials{}, err
+	}
+	creds := feedCredentials{}
+	if err := json.Unmarshal(dat, &creds); err != nil {
+		return feedCredentials{}, err
+	}
+	return creds, nil
+}
+
+func saveFeedCredentials(ctx context.Context, s *state, feedID uuid.UUID, creds feedCredentials) error {
+	dat, err := json.Marshal(creds)
+	if err != nil {
+		return err
+	}
+	sealed, err := sealSecret(s, dat, feedCredentialsAAD(feedID))
+	if err != nil {
+		return err
+	}
+	return s.db.UpsertFeedCredentials(ctx, database.UpsertFeedCredentialsParams{
+		FeedID:    feedID,
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+		Secret:    sealed,
+	})
+}
+
+// sealSecret encrypts dat with the local secret key, creating the key on
+// first use. aad names the row the value belongs to.
+func sealSecret(s *state, dat, aad []byte) ([]byte, error) {
+	path, err := s.cfg.SecretKeyPath()
+	if err != nil {
+		return nil, err
+	}
+	key, err := secrets.LoadOrCreateKey(path)
+	if err != nil {
+		return nil, fmt.Errorf("error loading secret key: %v", err)
+	}
+	return secrets.Seal(key, dat, aad)
+}
+
+// openSecret decrypts a value stored by sealSecret. It fails if the value
+// was sealed for a different row than aad names.
+func openSecret(s *state, sealed, aad []byte) ([]byte, error) {
+	path, err := s.cfg.SecretKeyPath()
+	if err != nil {
+		return nil, err
+	}
+	key, err := secrets.LoadKey(path)
+	if err != nil {
+		return nil, fmt.Errorf("error loading secret key: %v", err)
+	}
+	return secrets.Open(key, sealed, aad)
+}
+
+// headerFlags collects repeated --header "Name: value" flags.
+type headerFlags map[string]string
+
+func (h headerFlags) String() string {
+	return fmt.Sprint(map[string]string(h))
+}
+
+func (h headerFlags) Set(value string) error {
+	name, val, ok := strings.Cut(value, ":")
+	if !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("header %q must look like \"Name: value\"", value)
+	}
+	h[http.CanonicalHeaderKey(strings.TrimSpace(name))] = strings.TrimSpace(val)
+	return nil
+}
+
+func handlerFeedSetAuth(s *state, cmd command, user database.User) error {
+	fs := flag.NewFlagSet("feed set-auth", flag.ContinueOnError)
+	username := fs.String("basic", "", "use HTTP basic auth with this username; the password is prompted for")
+	bearer := fs.Bool("bearer", false, "send a bearer token, which is prompted for")
+	remove := fs.Bool("clear", false, "remove all credentials and headers for the feed")
+	headers := headerFlags{}
+	fs.Var(headers, "header", "extra request header as \"Name: value\" (repeatable)")
+	args, err := parseArgs(fs, cmd.arg)
+	if err != nil {
+		return err
+	}
+	if len(args) < 1 {
+		return errors.New("feed set-auth requires one argument: url")
+	}
+	feed, err := managedFeed(context.Background(), s, user, args[0], "set credentials for")
+	if err != nil {
+		return err
+	}
+
+	if *remove {
+		if err := s.db.DeleteFeedCredentials(context.Background(), feed.ID); err != nil {
+			return fmt.Errorf("error removing credentials: %v", err)
+		}
+		fmt.Printf("Credentials removed for %s\n", feed.Url)
+		return nil
+	}
+	if *username == "" && !*bearer && len(headers) == 0 {
+		return errors.New("feed set-auth needs --basic, --bearer, --header or --clear")
+	}
+
+	creds := feedCredentials{Username: *username, Headers: headers}
+	if *username != "" {
+		if creds.Password, err = promptSecret("Password: "); err != nil {
+			return fmt.Errorf("error reading password: %v", err)
+		}
+	}
+	if *bearer {
+		if creds.Token, err = promptSecret("Token: "); err != nil {
+			return fmt.Errorf("error reading token: %v", err)
+		}
+	}
+	if err := saveFeedCredentials(context.Background(), s, feed.ID, creds); err != nil {
+		return fmt.Errorf("error saving credentials: %v", err)
+	}
+	fmt.Printf("Credentials saved for %s\n", feed.Url)
+	return nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Lanrey-waju/gator.git/internal/database"
+)
+
+// managedFeed finds the feed at feedURL and checks that user may change it:
+// only the user who added a feed, or an admin, can.
+func managedFeed(ctx context.Context, s *state, user database.User, feedURL, action string) (database.Feed, error) {
+	feed, err := s.db.GetFeedByURL(ctx, feedURL)
+	if err != nil {
+		return database.Feed{}, fmt.Errorf("error retrieving feed with url %s: %v", feedURL, err)
+	}
+	if feed.UserID != user.ID && !user.IsAdmin {
+		if _, err := visibleFeed(ctx, s, user, feedURL); err != nil {
+			return database.Feed{}, err
+		}
+		return database.Feed{}, fmt.Errorf("only the user who added %s or an admin can %s it", feed.Url, action)
+	}
+	return feed, nil
+}
+
+func handlerFeedRename(s *state, cmd command, user database.User) error {
+	if len(cmd.arg) < 2 {
+		return errors.New("feed rename requires two arguments: url and new name")
+	}
+	name := strings.TrimSpace(cmd.arg[1])
+	if name == "" {
+		return errors.New("feed name cannot be empty")
+	}
+	feed, err := managedFeed(context.Background(), s, user, cmd.arg[0], "rename")
+	if err != nil {
+		return err
+	}
+	renamed, err := s.db.RenameFeed(context.Background(), database.RenameFeedParams{
+		Name:      name,
+		UpdatedAt: time.Now().UTC(),
+		ID:        feed.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("error renaming feed: %v", err)
+	}
+	fmt.Printf("Renamed %s to %s\n", feed.Name, renamed.Name)
+	return nil
+}
+
+// handlerFeedSetURL moves a feed to a new address, keeping its posts and
+// followers.
+func handlerFeedSetURL(s *state, cmd command, user database.User) error {
+	if len(cmd.arg) < 2 {
+		return errors.New("feed set-url requires two arguments: url and new url")
+	}
+	feed, err := managedFeed(context.Background(), s, user, cmd.arg[0], "move")
+	if err != nil {
+		return err
+	}
+	moved, err := s.db.SetFeedURL(context.Background(), database.SetFeedURLParams{
+		Url:       cmd.arg[1],
+		UpdatedAt: time.Now().UTC(),
+		ID:        feed.ID,
+	})
+	if isUniqueViolation(err) {
+		return fmt.Errorf("a feed with url %s already exists", cmd.arg[1])
+	} else if err != nil {
+		return fmt.Errorf("error changing feed url: %v", err)
+	}
+	fmt.Printf("%s moved from %s to %s\n", moved.Name, feed.Url, moved.Url)
+	return nil
+}
+
+// handlerFeedDelete deletes a feed along with its posts, everyone's follows
+// of it and the rules and webhooks scoped to it, so it asks first.
+func handlerFeedDelete(s *state, cmd command, user database.User) error {
+	fs := flag.NewFlagSet("feed delete", flag.ContinueOnError)
+	yes := fs.Bool("yes", false, "don't ask for confirmation")
+	args, err := parseArgs(fs, cmd.arg)
+	if err != nil {
+		return err
+	}
+	if len(args) < 1 {
+		return errors.New("feed delete requires one argument: url")
+	}
+	feed, err := managedFeed(context.Background(), s, user, args[0], "delete")
+	if err != nil {
+		return err
+	}
+	if !*yes {
+		ok, err := confirm(fmt.Sprintf("Delete %s with its posts and every follow, rule and webhook for it?", feed.Name))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New("feed delete cancelled")
+		}
+	}
+	if _, err := s.db.DeleteFeed(context.Background(), feed.ID); err != nil {
+		return fmt.Errorf("error deleting feed %s: %v", feed.Url, err)
+	}
+	fmt.Printf("Deleted %s (%s)\n", feed.Name, feed.Url)
+	return nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Lanrey-waju/gator.git/internal/database"
+)
+
+func handlerFeedStatus(s *state, cmd command, user database.User) error {
+	var feeds []database.Feed
+	if len(cmd.arg) == 0 {
+		all, err := s.db.GetAllFeeds(context.Background())
+		if err != nil {
+			return fmt.Errorf("error retrieving feeds: %v", err)
+		}
+		shared, err := sharedFeeds(context.Background(), s, user.ID)
+		if err != nil {
+			return err
+		}
+		for _, feed := range all {
+			if feedVisibleTo(feed, user, shared) {
+				feeds = append(feeds, feed)
+			}
+		}
+	}
+	for _, url := range cmd.arg {
+		feed, err := visibleFeed(context.Background(), s, user, url)
+		if err != nil {
+			return err
+		}
+		feeds = append(feeds, feed)
+	}
+
+	for _, feed := range feeds {
+		fmt.Printf("Feed: %s (%s)\n", feed.Name, feed.Url)
+		if !feed.LastFetchedAt.Valid {
+			fmt.Println("Last fetched: never")
+		} else {
+			fmt.Printf("Last fetched: %s\n", feed.LastFetchedAt.Time.Format(time.RFC1123))
+		}
+		if feed.LastStatus.Valid {
+			fmt.Printf("Status: %s\n", feed.LastStatus.String)
+		}
+		if feed.LastError.Valid {
+			fmt.Printf("Error: %s\n", feed.LastError.String)
+		}
+		if feed.NextFetchAt.Valid && feed.NextFetchAt.Time.After(time.Now().UTC()) {
+			fmt.Printf("Next fetch: not before %s\n", feed.NextFetchAt.Time.Format(time.RFC1123))
+		}
+		fmt.Println()
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Lanrey-waju/gator.git/internal/database"
+	"github.com/google/uuid"
+)
+
+// Feeds are public unless the user who added them says otherwise. Private
+// feeds are seen only by that user, and shared feeds also by the users they
+// are shared with. Users who can't see a feed can't follow it either.
+const (
+	visibilityPublic  = "public"
+	visibilityPrivate = "private"
+	visibilityShared  = "shared"
+)
+
+func checkVisibility(visibility string) error {
+	switch visibility {
+	case visibilityPublic, visibilityPrivate, visibilityShared:
+		return nil
+	}
+	return fmt.Errorf("visibility must be public, private or shared, not %q", visibility)
+}
+
+// sharedFeeds returns the IDs of the feeds shared with userID.
+func sharedFeeds(ctx context.Context, s *state, userID uuid.UUID) (map[uuid.UUID]bool, error) {
+	ids, err := s.db.GetFeedSharesForUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving feeds shared with you: %v", err)
+	}
+	shared := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		shared[id] = true
+	}
+	return shared, nil
+}
+
+func feedVisibleTo(feed database.Feed, user database.User, shared map[uuid.UUID]bool) bool {
+	switch {
+	case feed.Visibility == visibilityPublic, feed.UserID == user.ID:
+		return true
+	case feed.Visibility == visibilityShared:
+		return shared[feed.ID]
+	}
+	return false
+}
+
+// visibleFeed finds the feed at feedURL if user can see it. Feeds they
+// can't see are reported the same way as feeds that don't exist.
+func visibleFeed(ctx context.Context, s *state, user database.User, feedURL string) (database.Feed, error) {
+	feed, err := s.db.GetFeedByURL(ctx, feedURL)
+	if err == nil && feed.Visibility != visibilityPublic && feed.UserID != user.ID {
+		shared, serr := sharedFeeds(ctx, s, user.ID)
+		if serr != nil {
+			return database.Feed{}, serr
+		}
+		if !feedVisibleTo(feed, user, shared) {
+			err = sql.ErrNoRows
+		}
+	}
+	if err != nil {
+		return database.Feed{}, fmt.Errorf("error retrieving feed with url %s: %v", feedURL, err)
+	}
+	return feed, nil
+}
+
+// dropLostFollowers unfollows feed for everyone who can no longer see it,
+// and removes the webhooks and rules they had set up for it.
+func dropLostFollowers(ctx context.Context, s *state, feed database.Feed) error {
+	dropped, err := s.db.DeleteFeedFollowsWithoutAccess(ctx, feed.ID)
+	if err != nil {
+		return fmt.Errorf("error removing followers of %s: %v", feed.Url, err)
+	}
+	if dropped > 0 {
+		fmt.Printf("%d %s can no longer see %s and no longer follow it\n", dropped, pluralize(int(dropped), "user", "users"), feed.Name)
+	}
+	hooks, err := s.db.DeleteWebhooksWithoutAccess(ctx, feed.ID)
+	if err != nil {
+		return fmt.Errorf("error removing webhooks for %s: %v", feed.Url, err)
+	}
+	rules, err := s.db.DeleteRulesWithoutAccess(ctx, feed.ID)
+	if err != nil {
+		return fmt.Errorf("error removing rules for %s: %v", feed.Url, err)
+	}
+	if hooks > 0 || rules > 0 {
+		fmt.Printf("Removed %d %s and %d %s for %s set up by users who can no longer see it\n",
+			hooks, pluralize(int(hooks), "webhook", "webhooks"), rules, pluralize(int(rules), "rule", "rules"), feed.Name)
+	}
+	return nil
+}
+
+func handlerFeedVisibility(s *state, cmd command, user database.User) error {
+	if len(cmd.arg) < 2 {
+		return errors.New("feed visibility requires two arguments: url and public, private or shared")
+	}
+	visibility := strings.ToLower(cmd.arg[1])
+	if err := checkVisibility(visibility); err != nil {
+		return err
+	}
+	ctx := context.Background()
+	feed, err := managedFeed(ctx, s, user, cmd.arg[0], "change the visibility of")
+	if err != nil {
+		return err
+	}
+	feed, err = s.db.SetFeedVisibility(ctx, database.SetFeedVisibilityParams{
+		Visibility: visibility,
+		UpdatedAt:  time.Now().UTC(),
+		ID:         feed.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("error changing feed visibility: %v", err)
+	}
+	fmt.Printf("%s is now %s\n", feed.Name, feed.Visibility)
+	return dropLostFollowers(ctx, s, feed)
+}
+
+// handlerFeedShare shares a feed with the named users, or lists who it is
+// shared with when no users are given. Sharing a private feed makes it
+// shared.
+func handlerFeedShare(s *state, cmd command, user database.User) error {
+	if len(cmd.arg) < 1 {
+		return errors.New("feed share requires at least one argument: url, then the users to share it with")
+	}
+	ctx := context.Background()
+	feed, err := managedFeed(ctx, s, user, cmd.arg[0], "share")
+	if err != nil {
+		return err
+	}
+	if len(cmd.arg) == 1 {
+		names, err := s.db.GetFeedShares(ctx, feed.ID)
+		if err != nil {
+			return fmt.Errorf("error retrieving shares: %v", err)
+		}
+		fmt.Printf("%s is %s\n", feed.Name, feed.Visibility)
+		for _, name := range names {
+			fmt.Printf("* %s\n", name)
+		}
+		return nil
+	}
+
+	for _, name := range cmd.arg[1:] {
+		other, err := s.db.GetUserByName(ctx, name)
+		if err != nil {
+			return fmt.Errorf("error retrieving user %s: %v", name, err)
+		}
+		err = s.db.AddFeedShare(ctx, database.AddFeedShareParams{
+			FeedID:    feed.ID,
+			UserID:    other.ID,
+			CreatedAt: time.Now().UTC(),
+		})
+		if err != nil {
+			return fmt.Errorf("error sharing feed with %s: %v", name, err)
+		}
+		fmt.Printf("Shared %s with %s\n", feed.Name, other.Name)
+	}
+	switch feed.Visibility {
+	case visibilityPrivate:
+		_, err := s.db.SetFeedVisibility(ctx, database.SetFeedVisibilityParams{
+			Visibility: visibilityShared,
+			UpdatedAt:  time.Now().UTC(),
+			ID:         feed.ID,
+		})
+		if err != nil {
+			return fmt.Errorf("error changing feed visibility: %v", err)
+		}
+		fmt.Printf("%s is now shared\n", feed.Name)
+	case visibilityPublic:
+		fmt.Printf("%s is public, so everyone can already see it\n", feed.Name)
+	}
+	return nil
+}
+
+func handlerFeedUnshare(s *state, cmd command, user database.User) error {
+	if len(cmd.arg) < 2 {
+		return errors.New("feed unshare requires at least two arguments: url, then the users to stop sharing it with")
+	}
+	ctx := context.Background()
+	feed, err := managedFeed(ctx, s, user, cmd.arg[0], "unshare")
+	if err != nil {
+		return err
+	}
+	for _, name := range cmd.arg[1:] {
+		other, err := s.db.GetUserByName(ctx, name)
+		if err != nil {
+			return fmt.Errorf("error retrieving user %s: %v", name, err)
+		}
+		removed, err := s.db.RemoveFeedShare(ctx, database.RemoveFeedShareParams{FeedID: feed.ID, UserID: other.ID})
+		if err != nil {
+			return fmt.Errorf("error unsharing feed: %v", err)
+		}
+		if removed == 0 {
+			return fmt.Errorf("%s is not shared with %s", feed.Name, other.Name)
+		}
+		fmt.Printf("Stopped sharing %s with %s\n", feed.Name, other.Name)
+	}
+	return dropLostFollowers(ctx, s, feed)
+}
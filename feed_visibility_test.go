@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Lanrey-waju/gator.git/internal/config"
+	"github.com/Lanrey-waju/gator.git/internal/database"
+	"github.com/google/uuid"
+)
+
+// testDB migrates a schema of its own in the database named by
+// GATOR_TEST_DATABASE_URL and returns queries that use it. Tests that need
+// Postgres are skipped when the variable is not set.
+func testDB(t *testing.T) *database.Queries {
+	t.Helper()
+	dbURL := os.Getenv("GATOR_TEST_DATABASE_URL")
+	if dbURL == "" {
+		t.Skip("GATOR_TEST_DATABASE_URL is not set")
+	}
+	admin, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Close() })
+	schema := "gator_test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatalf("error creating schema: %v", err)
+	}
+	t.Cleanup(func() { admin.Exec("DROP SCHEMA " + schema + " CASCADE") })
+
+	sep := "?"
+	if strings.Contains(dbURL, "?") {
+		sep = "&"
+	}
+	if !strings.Contains(dbURL, "://") {
+		sep = " "
+	}
+	db, err := sql.Open("postgres", dbURL+sep+"search_path="+schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	files, err := filepath.Glob(filepath.Join("sql", "schema", "*.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		dat, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		up, _, _ := strings.Cut(string(dat), "-- +goose Down")
+		if _, err := db.Exec(up); err != nil {
+			t.Fatalf("error applying %s: %v", file, err)
+		}
+	}
+	return database.New(db)
+}
+
+func TestFeedVisibleTo(t *testing.T) {
+	owner := database.User{ID: uuid.New()}
+	friend := database.User{ID: uuid.New()}
+	feedID := uuid.New()
+	sharedWithFriend := map[uuid.UUID]bool{feedID: true}
+	tests := []struct {
+		visibility string
+		user       database.User
+		shared     map[uuid.UUID]bool
+		want       bool
+	}{
+		{visibilityPublic, friend, nil, true},
+		{visibilityPrivate, owner, nil, true},
+		{visibilityPrivate, friend, nil, false},
+		{visibilityPrivate, friend, sharedWithFriend, false},
+		{visibilityShared, owner, nil, true},
+		{visibilityShared, friend, sharedWithFriend, true},
+		{visibilityShared, friend, nil, false},
+	}
+	for _, tt := range tests {
+		feed := database.Feed{ID: feedID, UserID: owner.ID, Visibility: tt.visibility}
+		if got := feedVisibleTo(feed, tt.user, tt.shared); got != tt.want {
+			t.Errorf("%s feed visible to owner %t with share %t = %t, want %t",
+				tt.visibility, tt.user.ID == owner.ID, tt.shared[feedID], got, tt.want)
+		}
+	}
+}
+
+func TestUnshareStopsWebhooks(t *testing.T) {
+	q := testDB(t)
+	ctx := context.Background()
+	s := &state{db: q, cfg: &config.Config{}, fetcher: newFetcher(config.HTTPConfig{})}
+	now := time.Now().UTC()
+
+	var owner, friend database.User
+	for _, u := range []struct {
+		name string
+		user *database.User
+	}{{"owner", &owner}, {"friend", &friend}} {
+		created, err := q.CreateUser(ctx, database.CreateUserParams{ID: uuid.New(), CreatedAt: now, UpdatedAt: now, Name: u.name})
+		if err != nil {
+			t.Fatalf("error creating user %s: %v", u.name, err)
+		}
+		*u.user = created
+	}
+	feed, err := q.CreateFeed(ctx, database.CreateFeedParams{
+		ID: uuid.New(), CreatedAt: now, UpdatedAt: now,
+		Name: "Team notes", Url: "https://example.com/team.xml", UserID: owner.ID, Visibility: visibilityShared,
+	})
+	if err != nil {
+		t.Fatalf("error creating feed: %v", err)
+	}
+	if err := q.AddFeedShare(ctx, database.AddFeedShareParams{FeedID: feed.ID, UserID: friend.ID, CreatedAt: now}); err != nil {
+		t.Fatalf("error sharing feed: %v", err)
+	}
+	if _, err := q.CreateFeedFollow(ctx, database.CreateFeedFollowParams{
+		ID: uuid.New(), CreatedAt: now, UpdatedAt: now, UserID: friend.ID, FeedID: feed.ID,
+	}); err != nil {
+		t.Fatalf("error following feed: %v", err)
+	}
+
+	rec, srv := newWebhookReceiver(t, http.StatusNoContent)
+	for _, feedID := range []uuid.NullUUID{{UUID: feed.ID, Valid: true}, {}} {
+		if _, err := q.CreateWebhook(ctx, database.CreateWebhookParams{
+			ID: uuid.New(), CreatedAt: now, UpdatedAt: now, UserID: friend.ID, Url: srv.URL, FeedID: feedID,
+		}); err != nil {
+			t.Fatalf("error creating webhook: %v", err)
+		}
+	}
+	if _, err := q.CreateRule(ctx, database.CreateRuleParams{
+		ID: uuid.New(), CreatedAt: now, UpdatedAt: now, UserID: friend.ID,
+		FeedID: uuid.NullUUID{UUID: feed.ID, Valid: true}, TitleMatches: ".", Action: ruleStar,
+	}); err != nil {
+		t.Fatalf("error creating rule: %v", err)
+	}
+
+	notify := func(title string) int {
+		t.Helper()
+		post, err := q.CreatePost(ctx, database.CreatePostParams{
+			ID: uuid.New(), CreatedAt: now, UpdatedAt: now, Title: title,
+			Url: fmt.Sprintf("https://example.com/%s", uuid.NewString()), FeedID: feed.ID,
+		})
+		if err != nil {
+			t.Fatalf("error creating post: %v", err)
+		}
+		rec.mu.Lock()
+		before := len(rec.requests)
+		rec.mu.Unlock()
+		notifyWebhooks(ctx, s, feed, []database.Post{post}, applyIngestRules(ctx, s, feed, []database.Post{post}))
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		return len(rec.requests) - before
+	}
+
+	if sent := notify("Shared post"); sent != 2 {
+		t.Fatalf("%d webhooks fired while the feed was shared, want 2", sent)
+	}
+	if err := handlerFeedUnshare(s, command{name: "feed unshare", arg: []string{feed.Url, friend.Name}}, owner); err != nil {
+		t.Fatalf("feed unshare returned error: %v", err)
+	}
+	if sent := notify("Post after unsharing"); sent != 0 {
+		t.Errorf("%d webhooks fired after the feed was unshared", sent)
+	}
+	if hooks, err := q.GetWebhooksForUser(ctx, friend.ID); err != nil || len(hooks) != 1 {
+		t.Errorf("friend has %d webhooks left, %v; want only the one without a feed", len(hooks), err)
+	}
+	if rules, err := q.GetRulesForFeed(ctx, feed.ID); err != nil || len(rules) != 0 {
+		t.Errorf("%d rules still apply to the feed, %v", len(rules), err)
+	}
+}
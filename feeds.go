@@ -1,49 +1,112 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/xml"
+	"fmt"
 	"html"
 	"io"
+	"log/slog"
 	"net/http"
+	"strings"
+	"time"
 )
 
 type RSSFeed struct {
 	Channel struct {
-		Title       string    `xml:"title"`
-		Link        string    `xml:"link"`
-		Description string    `xml:"description"`
-		Item        []RSSItem `xml:"item"`
+		Title       string      `xml:"title"`
+		Link        string      `xml:"link"`
+		Description string      `xml:"description"`
+		Image       ITunesImage `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd image"`
+		Item        []RSSItem   `xml:"item"`
 	} `xml:"channel"`
 }
 
 type RSSItem struct {
-	Title       string `xml:"title"`
-	Link        string `xml:"link"`
-	Description string `xml:"description"`
-	PubDate     string `xml:"pubDate"`
+	Title       string         `xml:"title"`
+	Link        string         `xml:"link"`
+	Description string         `xml:"description"`
+	PubDate     string         `xml:"pubDate"`
+	DCDate      string         `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Published   string         `xml:"http://www.w3.org/2005/Atom published"`
+	Updated     string         `xml:"http://www.w3.org/2005/Atom updated"`
+	Author      string         `xml:"author"`
+	Creator     string         `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Categories  []string       `xml:"category"`
+	Enclosures  []RSSEnclosure `xml:"enclosure"`
+	Content     string         `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	Duration    string         `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration"`
+	Episode     string         `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd episode"`
+	Image       ITunesImage    `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd image"`
 }
 
-func fetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
+type RSSEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+type ITunesImage struct {
+	Href string `xml:"href,attr"`
+}
+
+func fetchFeed(ctx context.Context, f *fetcher, feedURL string, creds feedCredentials) (_ *RSSFeed, err error) {
+	started := time.Now()
+	defer func() {
+		observeFetch(started, err)
+		slog.Debug("fetched feed", "url", feedURL, "duration", time.Since(started), "err", err)
+	}()
 	rssFeed := &RSSFeed{}
-	client := &http.Client{}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feedURL, nil)
+	req, err := f.newRequest(ctx, feedURL)
 	if err != nil {
 		return &RSSFeed{}, err
 	}
-	req.Header.Add("User-Agent", "gator")
-	resp, err := client.Do(req)
+	if until, ok := f.hostBackoff(req.URL.Host); ok {
+		return &RSSFeed{}, &retryLaterError{until: until, reason: req.URL.Host + " asked us to slow down"}
+	}
+	allowed, err := f.robotsAllowed(ctx, feedURL)
+	if err != nil {
+		return &RSSFeed{}, err
+	}
+	if !allowed {
+		return &RSSFeed{}, errRobotsDisallowed
+	}
+	req.Header.Add("Accept", "application/rss+xml, application/atom+xml, application/xml;q=0.9, text/xml;q=0.9, */*;q=0.1")
+	req.Header.Add("Accept-Encoding", "gzip, deflate, br")
+	creds.apply(req)
+	resp, err := f.do(req)
 	if err != nil {
 		return &RSSFeed{}, err
 	}
 	defer resp.Body.Close()
 
-	dat, err := io.ReadAll(resp.Body)
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		until := retryAfter(resp.Header.Get("Retry-After"))
+		f.postponeHost(req.URL.Host, until)
+		return &RSSFeed{}, &retryLaterError{until: until, reason: resp.Status}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &RSSFeed{}, fmt.Errorf("%w %s", errBadStatus, resp.Status)
+	}
+	if err := checkFeedContentType(resp.Header.Get("Content-Type")); err != nil {
+		return &RSSFeed{}, err
+	}
+	dat, err := f.readBody(resp)
 	if err != nil {
 		return &RSSFeed{}, err
 	}
-	if err := xml.Unmarshal(dat, rssFeed); err != nil {
+	dat, err = toUTF8(resp.Header.Get("Content-Type"), dat)
+	if err != nil {
+		return &RSSFeed{}, err
+	}
+	decoder := xml.NewDecoder(bytes.NewReader(dat))
+	// the body is already UTF-8, whatever the XML declaration says
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	if err := decoder.Decode(rssFeed); err != nil {
 		return &RSSFeed{}, err
 	}
 	rssFeed.Channel.Title = html.UnescapeString(rssFeed.Channel.Title)
@@ -51,6 +114,22 @@ func fetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
 	for i := range rssFeed.Channel.Item {
 		rssFeed.Channel.Item[i].Title = html.UnescapeString(rssFeed.Channel.Item[i].Title)
 		rssFeed.Channel.Item[i].Description = html.UnescapeString(rssFeed.Channel.Item[i].Description)
+		rssFeed.Channel.Item[i].Author = strings.TrimSpace(html.UnescapeString(rssFeed.Channel.Item[i].Author))
+		rssFeed.Channel.Item[i].Creator = strings.TrimSpace(html.UnescapeString(rssFeed.Channel.Item[i].Creator))
+		var categories []string
+		for _, category := range rssFeed.Channel.Item[i].Categories {
+			category = strings.TrimSpace(html.UnescapeString(category))
+			if category != "" {
+				categories = append(categories, category)
+			}
+		}
+		rssFeed.Channel.Item[i].Categories = categories
+		rssFeed.Channel.Item[i].Duration = strings.TrimSpace(rssFeed.Channel.Item[i].Duration)
+		rssFeed.Channel.Item[i].Episode = strings.TrimSpace(rssFeed.Channel.Item[i].Episode)
+		// episodes without artwork of their own use the show's artwork
+		if rssFeed.Channel.Item[i].Image.Href == "" {
+			rssFeed.Channel.Item[i].Image = rssFeed.Channel.Image
+		}
 	}
 	return rssFeed, nil
 
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Lanrey-waju/gator.git/internal/config"
+	"github.com/andybalholm/brotli"
+)
+
+const (
+	defaultConnectTimeout = 10 * time.Second
+	defaultReadTimeout    = 30 * time.Second
+	defaultMaxBodyBytes   = 10 << 20
+	defaultUserAgent      = "gator/1.0"
+	defaultContactURL     = "https://github.com/Lanrey-waju/gator"
+	defaultHostInterval   = 10 * time.Second
+	defaultHostBurst      = 2
+	defaultMinSpacing     = 500 * time.Millisecond
+)
+
+// fetcher holds the HTTP client shared by every feed request, so that
+// connections are reused across feeds and the limits from the config file
+// apply everywhere.
+type fetcher struct {
+	transport    *http.Transport
+	client       *http.Client
+	maxBodyBytes int64
+	userAgent    string
+	limiter      *hostLimiter
+	robots       *robotsCache
+
+	backoffMu sync.Mutex
+	backoff   map[string]time.Time
+}
+
+// Without a Retry-After header we wait defaultRetryAfter, and a header can
+// never keep us away for longer than maxRetryAfter.
+const (
+	defaultRetryAfter = 5 * time.Minute
+	maxRetryAfter     = 24 * time.Hour
+)
+
+var (
+	errRobotsDisallowed = errors.New("fetching is disallowed by robots.txt")
+	errBadStatus        = errors.New("unexpected status")
+	errNotAFeed         = errors.New("response is not a feed")
+	errBodyTooLarge     = errors.New("response is too large")
+)
+
+// retryLaterError is returned when a server answers 429 or 503, or when an
+// earlier answer asked us to leave its host alone for a while. It is a
+// scheduling signal rather than a failure.
+type retryLaterError struct {
+	until  time.Time
+	reason string
+}
+
+func (e *retryLaterError) Error() string {
+	return fmt.Sprintf("%s, retrying after %s", e.reason, e.until.Format(time.RFC1123))
+}
+
+func newFetcher(cfg config.HTTPConfig) *fetcher {
+	connectTimeout := cfg.ConnectTimeout.Duration
+	if connectTimeout <= 0 {
+		connectTimeout = defaultConnectTimeout
+	}
+	readTimeout := cfg.ReadTimeout.Duration
+	if readTimeout <= 0 {
+		readTimeout = defaultReadTimeout
+	}
+	maxBodyBytes := cfg.MaxBodyBytes
+	if maxBodyBytes <= 0 {
+		maxBodyBytes = defaultMaxBodyBytes
+	}
+	userAgent := cfg.UserAgent
+	if userAgent == "" {
+		userAgent = defaultUserAgent
+	}
+	contactURL := cfg.ContactURL
+	if contactURL == "" {
+		contactURL = defaultContactURL
+	}
+	hostInterval := cfg.HostInterval.Duration
+	if hostInterval <= 0 {
+		hostInterval = defaultHostInterval
+	}
+	hostBurst := cfg.HostBurst
+	if hostBurst <= 0 {
+		hostBurst = defaultHostBurst
+	}
+	minSpacing := cfg.MinSpacing.Duration
+	if minSpacing <= 0 {
+		minSpacing = defaultMinSpacing
+	}
+
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: connectTimeout}).DialContext,
+		TLSHandshakeTimeout:   connectTimeout,
+		ResponseHeaderTimeout: readTimeout,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConnsPerHost:   4,
+		ForceAttemptHTTP2:     true,
+	}
+	return &fetcher{
+		transport: transport,
+		client: &http.Client{
+			Transport:     transport,
+			Timeout:       connectTimeout + readTimeout,
+			CheckRedirect: checkRedirect,
+		},
+		maxBodyBytes: maxBodyBytes,
+		userAgent:    userAgentWithContact(userAgent, contactURL),
+		limiter:      newHostLimiter(hostInterval, hostBurst, minSpacing),
+		robots:       newRobotsCache(),
+		backoff:      make(map[string]time.Time),
+	}
+}
+
+// userAgentWithContact adds the contact URL to userAgent, unless the
+// configured User-Agent already carries one. Publishers ask for a way to
+// reach whoever is polling them.
+func userAgentWithContact(userAgent, contactURL string) string {
+	if strings.Contains(userAgent, "+http") {
+		return userAgent
+	}
+	return fmt.Sprintf("%s (+%s)", userAgent, contactURL)
+}
+
+// hostBackoff returns when host may be requested again, if it has asked us
+// to back off.
+func (f *fetcher) hostBackoff(host string) (time.Time, bool) {
+	f.backoffMu.Lock()
+	defer f.backoffMu.Unlock()
+	until, ok := f.backoff[strings.ToLower(host)]
+	if !ok || time.Now().After(until) {
+		return time.Time{}, false
+	}
+	return until, true
+}
+
+func (f *fetcher) postponeHost(host string, until time.Time) {
+	f.backoffMu.Lock()
+	defer f.backoffMu.Unlock()
+	f.backoff[strings.ToLower(host)] = until
+}
+
+// inheritHostState carries what old has learned about hosts over to f, which
+// was built from a reloaded config: the per-host rate limiters, the
+// robots.txt cache and any Retry-After backoff.
+func (f *fetcher) inheritHostState(old *fetcher) {
+	old.limiter.reconfigure(f.limiter)
+	f.limiter = old.limiter
+	// cached rules were picked for the old agent token
+	if f.agentToken() == old.agentToken() {
+		f.robots = old.robots
+	}
+	old.backoffMu.Lock()
+	defer old.backoffMu.Unlock()
+	for host, until := range old.backoff {
+		f.backoff[host] = until
+	}
+}
+
+// redirectSafeHeaders are the only headers kept when a redirect leaves the
+// original host. Go already drops Authorization and Cookie then, but not the
+// custom headers from feed set-auth, which often carry API keys.
+var redirectSafeHeaders = []string{"User-Agent", "Accept", "Accept-Encoding", "Accept-Language", "Range"}
+
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	if strings.EqualFold(req.URL.Host, via[0].URL.Host) {
+		return nil
+	}
+	kept := http.Header{}
+	for _, name := range redirectSafeHeaders {
+		if values := req.Header.Values(name); len(values) > 0 {
+			kept[name] = values
+		}
+	}
+	req.Header = kept
+	return nil
+}
+
+// retryAfter interprets a Retry-After header, which is either a number of
+// seconds or an HTTP date.
+func retryAfter(header string) time.Time {
+	now := time.Now()
+	wait := defaultRetryAfter
+	if seconds, err := strconv.Atoi(strings.TrimSpace(header)); err == nil && seconds >= 0 {
+		wait = time.Duration(seconds) * time.Second
+	} else if t, err := http.ParseTime(header); err == nil {
+		wait = t.Sub(now)
+	}
+	if wait < 0 {
+		wait = 0
+	}
+	return now.Add(min(wait, maxRetryAfter))
+}
+
+// do sends req once the rate limiter allows another request to its host.
+func (f *fetcher) do(req *http.Request) (*http.Response, error) {
+	if err := f.limiter.wait(req.Context(), req.URL.Host); err != nil {
+		return nil, err
+	}
+	return f.client.Do(req)
+}
+
+// doStreaming is like do but without an overall timeout, for downloads
+// that may legitimately take a long time.
+func (f *fetcher) doStreaming(req *http.Request) (*http.Response, error) {
+	if err := f.limiter.wait(req.Context(), req.URL.Host); err != nil {
+		return nil, err
+	}
+	client := &http.Client{Transport: f.transport, CheckRedirect: checkRedirect}
+	return client.Do(req)
+}
+
+// readBody decompresses resp according to its Content-Encoding and reads it,
+// failing once more than maxBodyBytes have been decoded. The limit applies
+// after decompression so a small compressed body cannot expand without bound.
+func (f *fetcher) readBody(resp *http.Response) ([]byte, error) {
+	if resp.ContentLength > f.maxBodyBytes {
+		return nil, fmt.Errorf("%w: %d bytes exceeds the %d byte limit", errBodyTooLarge, resp.ContentLength, f.maxBodyBytes)
+	}
+	body, err := decodeContent(resp.Header.Get("Content-Encoding"), countingReader{resp.Body})
+	if err != nil {
+		return nil, err
+	}
+	dat, err := io.ReadAll(io.LimitReader(body, f.maxBodyBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(dat)) > f.maxBodyBytes {
+		return nil, fmt.Errorf("%w: more than %d bytes", errBodyTooLarge, f.maxBodyBytes)
+	}
+	return dat, nil
+}
+
+func decodeContent(encoding string, body io.Reader) (io.Reader, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "", "identity":
+		return body, nil
+	case "gzip", "x-gzip":
+		return gzip.NewReader(body)
+	case "br":
+		return brotli.NewReader(body), nil
+	case "deflate":
+		// "deflate" is meant to be zlib-wrapped, but some servers send a
+		// raw deflate stream instead
+		buffered := bufio.NewReader(body)
+		header, err := buffered.Peek(2)
+		if err == nil && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+			return zlib.NewReader(buffered)
+		}
+		return flate.NewReader(buffered), nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+}
+
+// checkFeedContentType rejects responses that are clearly not feeds, such as
+// an HTML landing page or an image. Feeds are served with all sorts of
+// content types, so anything not obviously wrong is let through to the parser.
+func checkFeedContentType(contentType string) error {
+	if contentType == "" {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil
+	}
+	switch {
+	case mediaType == "text/html", mediaType == "application/xhtml+xml":
+		return fmt.Errorf("%w: server returned an HTML page (%s)", errNotAFeed, mediaType)
+	case mediaType == "application/json", mediaType == "application/pdf", mediaType == "application/zip",
+		strings.HasPrefix(mediaType, "image/"), strings.HasPrefix(mediaType, "audio/"),
+		strings.HasPrefix(mediaType, "video/"), strings.HasPrefix(mediaType, "font/"):
+		return fmt.Errorf("%w: server returned %s content", errNotAFeed, mediaType)
+	}
+	return nil
+}
+
+func (f *fetcher) newRequest(ctx context.Context, rawURL string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("User-Agent", f.userAgent)
+	return req, nil
+}
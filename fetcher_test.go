@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Lanrey-waju/gator.git/internal/config"
+	"github.com/andybalholm/brotli"
+)
+
+// compress encodes dat with the named Content-Encoding.
+func compress(t *testing.T, encoding string, dat []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	case "zlib":
+		w = zlib.NewWriter(&buf)
+	case "flate":
+		var err error
+		if w, err = flate.NewWriter(&buf, flate.DefaultCompression); err != nil {
+			t.Fatal(err)
+		}
+	default:
+		t.Fatalf("unknown encoding %s", encoding)
+	}
+	if _, err := w.Write(dat); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeContent(t *testing.T) {
+	feed := []byte(`<?xml version="1.0"?><rss><channel><title>Compressed</title></channel></rss>`)
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+	}{
+		{"no encoding", "", feed},
+		{"identity", "identity", feed},
+		{"gzip", "gzip", compress(t, "gzip", feed)},
+		{"x-gzip", "x-gzip", compress(t, "gzip", feed)},
+		{"upper-case gzip", " GZIP ", compress(t, "gzip", feed)},
+		{"brotli", "br", compress(t, "br", feed)},
+		{"zlib-wrapped deflate", "deflate", compress(t, "zlib", feed)},
+		{"raw deflate", "deflate", compress(t, "flate", feed)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := decodeContent(tt.encoding, bytes.NewReader(tt.body))
+			if err != nil {
+				t.Fatalf("decodeContent returned error: %v", err)
+			}
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("error reading decoded body: %v", err)
+			}
+			if !bytes.Equal(got, feed) {
+				t.Errorf("decoded body = %q, want %q", got, feed)
+			}
+		})
+	}
+
+	if _, err := decodeContent("compress", strings.NewReader("x")); err == nil {
+		t.Error("decodeContent accepted an unsupported encoding")
+	}
+	if _, err := decodeContent("gzip", strings.NewReader("not gzip")); err == nil {
+		t.Error("decodeContent accepted a body that is not gzip")
+	}
+}
+
+func TestReadBodyLimits(t *testing.T) {
+	const limit = 64 << 10
+	f := newFetcher(config.HTTPConfig{MaxBodyBytes: limit})
+	zeros := make([]byte, 16<<20)
+	tests := []struct {
+		name          string
+		encoding      string
+		body          []byte
+		contentLength int64
+		wantErr       bool
+	}{
+		{"under the limit", "", bytes.Repeat([]byte("a"), limit-1), -1, false},
+		{"exactly the limit", "", bytes.Repeat([]byte("a"), limit), -1, false},
+		{"one byte over", "", bytes.Repeat([]byte("a"), limit+1), -1, true},
+		{"declared length over", "", []byte("short"), limit + 1, true},
+		{"gzip bomb", "gzip", compress(t, "gzip", zeros), -1, true},
+		{"brotli bomb", "br", compress(t, "br", zeros), -1, true},
+		{"deflate bomb", "deflate", compress(t, "zlib", zeros), -1, true},
+		{"small gzip", "gzip", compress(t, "gzip", zeros[:1024]), -1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{
+				Header:        http.Header{},
+				Body:          io.NopCloser(bytes.NewReader(tt.body)),
+				ContentLength: tt.contentLength,
+			}
+			if tt.encoding != "" {
+				resp.Header.Set("Content-Encoding", tt.encoding)
+			}
+			dat, err := f.readBody(resp)
+			if tt.wantErr {
+				if !errors.Is(err, errBodyTooLarge) {
+					t.Errorf("readBody = %d bytes, %v; want errBodyTooLarge", len(dat), err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readBody returned error: %v", err)
+			}
+			if len(dat) > limit {
+				t.Errorf("readBody returned %d bytes, more than the %d byte limit", len(dat), limit)
+			}
+		})
+	}
+}
+
+func TestCheckFeedContentType(t *testing.T) {
+	tests := []struct {
+		contentType string
+		wantErr     bool
+	}{
+		{"", false},
+		{"application/rss+xml", false},
+		{"application/atom+xml; charset=utf-8", false},
+		{"text/xml", false},
+		{"application/xml", false},
+		{"text/plain", false},
+		{"application/octet-stream", false},
+		{"not a media type;;", false},
+		{"text/html; charset=utf-8", true},
+		{"application/xhtml+xml", true},
+		{"application/json", true},
+		{"image/png", true},
+		{"audio/mpeg", true},
+		{"video/mp4", true},
+		{"application/pdf", true},
+		{"application/zip", true},
+		{"font/woff2", true},
+	}
+	for _, tt := range tests {
+		err := checkFeedContentType(tt.contentType)
+		if tt.wantErr != errors.Is(err, errNotAFeed) {
+			t.Errorf("checkFeedContentType(%q) = %v, want error %t", tt.contentType, err, tt.wantErr)
+		}
+	}
+}
+
+func TestRedirectHeaders(t *testing.T) {
+	creds := feedCredentials{
+		Token:   "t0ken",
+		Headers: map[string]string{"X-Api-Key": "k3y", "Accept-Language": "de"},
+	}
+	tests := []struct {
+		name      string
+		crossHost bool
+		wantKept  []string
+		wantGone  []string
+	}{
+		{"same host keeps everything", false, []string{"X-Api-Key", "Authorization", "User-Agent", "Accept-Language"}, nil},
+		{"other host keeps only safe headers", true, []string{"User-Agent", "Accept-Language"}, []string{"X-Api-Key", "Authorization"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make(chan http.Header, 1)
+			target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/feed" {
+					got <- r.Header.Clone()
+					return
+				}
+				http.Redirect(w, r, "/feed", http.StatusFound)
+			}))
+			defer target.Close()
+			start := target.URL + "/old"
+			if tt.crossHost {
+				// a second server listens on another port, which makes it
+				// another host as far as redirects are concerned
+				origin := httptest.NewServer(http.RedirectHandler(target.URL+"/feed", http.StatusMovedPermanently))
+				defer origin.Close()
+				start = origin.URL + "/old"
+			}
+
+			f := testFetcher()
+			req, err := f.newRequest(context.Background(), start)
+			if err != nil {
+				t.Fatal(err)
+			}
+			creds.apply(req)
+			req.Header.Set("Accept-Language", "de")
+			resp, err := f.do(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			resp.Body.Close()
+			header := <-got
+			for _, name := range tt.wantKept {
+				if header.Get(name) == "" {
+					t.Errorf("%s was dropped", name)
+				}
+			}
+			for _, name := range tt.wantGone {
+				if value := header.Get(name); value != "" {
+					t.Errorf("%s = %q was sent to the other host", name, value)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckRedirectLimit(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	via := make([]*http.Request, 10)
+	for i := range via {
+		via[i] = req
+	}
+	if err := checkRedirect(req, via); err == nil {
+		t.Error("checkRedirect allowed an eleventh redirect")
+	}
+	if err := checkRedirect(req, via[:9]); err != nil {
+		t.Errorf("checkRedirect refused a tenth redirect: %v", err)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name   string
+		header string
+		want   time.Duration
+	}{
+		{"missing", "", defaultRetryAfter},
+		{"seconds", "120", 2 * time.Minute},
+		{"seconds with spaces", " 30 ", 30 * time.Second},
+		{"zero seconds", "0", 0},
+		{"negative seconds", "-5", defaultRetryAfter},
+		{"garbage", "soon", defaultRetryAfter},
+		{"seconds past the cap", "604800", maxRetryAfter},
+		{"http date", now.Add(10 * time.Minute).UTC().Format(http.TimeFormat), 10 * time.Minute},
+		{"http date in the past", now.Add(-time.Hour).UTC().Format(http.TimeFormat), 0},
+		{"http date past the cap", now.Add(72 * time.Hour).UTC().Format(http.TimeFormat), maxRetryAfter},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// HTTP dates only have whole seconds, so allow a little slack
+			got := time.Until(retryAfter(tt.header))
+			if got < tt.want-2*time.Second || got > tt.want+time.Second {
+				t.Errorf("retryAfter(%q) waits %s, want %s", tt.header, got.Round(time.Second), tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchFeedPostponesHost(t *testing.T) {
+	feedRequests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		feedRequests++
+		w.Header().Set("Retry-After", "600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	f := testFetcher()
+	for i := 0; i < 2; i++ {
+		_, err := fetchFeed(context.Background(), f, srv.URL+"/feed.xml", feedCredentials{})
+		var later *retryLaterError
+		if !errors.As(err, &later) {
+			t.Fatalf("fetch %d returned %v, want a retryLaterError", i+1, err)
+		}
+		if wait := time.Until(later.until); wait < 9*time.Minute || wait > 10*time.Minute {
+			t.Errorf("fetch %d asks to retry in %s, want 10m", i+1, wait.Round(time.Second))
+		}
+	}
+	if feedRequests != 1 {
+		t.Errorf("the feed was requested %d times, want once before the host is postponed", feedRequests)
+	}
+}
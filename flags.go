@@ -0,0 +1,21 @@
+package main
+
+import "flag"
+
+// parseArgs parses flags wherever they appear in args, so that both
+// "browse 5 --author x" and "browse --author x 5" work, and returns the
+// remaining positional arguments in order.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
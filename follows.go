@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Lanrey-waju/gator.git/internal/database"
+)
+
+// handlerFollowSet changes how one of user's follows is shown: the name
+// used for the feed, its priority in browse and digests, and whether it is
+// muted or sends notifications. Flags that are not given keep their value.
+func handlerFollowSet(s *state, cmd command, user database.User) error {
+	fs := flag.NewFlagSet("follows set", flag.ContinueOnError)
+	title := fs.String("title", "", "show the feed under this name; an empty name restores the feed's own")
+	priority := fs.Int("priority", 0, "posts from higher priority feeds are listed first")
+	muted := fs.Bool("muted", false, "leave the feed's posts out of browse, digests and notifications")
+	notify := fs.Bool("notify", true, "send the feed's new posts to your webhooks")
+	args, err := parseArgs(fs, cmd.arg)
+	if err != nil {
+		return err
+	}
+	if len(args) < 1 {
+		return errors.New("follows set requires one argument: url")
+	}
+	follow, err := userFollow(context.Background(), s, user, args[0])
+	if err != nil {
+		return err
+	}
+
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if len(set) == 0 {
+		return errors.New("follows set needs at least one of --title, --priority, --muted or --notify")
+	}
+	params := database.UpdateFeedFollowSettingsParams{
+		CustomTitle: follow.CustomTitle,
+		Priority:    follow.Priority,
+		Muted:       follow.Muted,
+		Notify:      follow.Notify,
+		UpdatedAt:   time.Now().UTC(),
+		ID:          follow.ID,
+	}
+	if set["title"] {
+		name := strings.TrimSpace(*title)
+		params.CustomTitle = sql.NullString{String: name, Valid: name != ""}
+	}
+	if set["priority"] {
+		params.Priority = int32(*priority)
+	}
+	if set["muted"] {
+		params.Muted = *muted
+	}
+	if set["notify"] {
+		params.Notify = *notify
+	}
+	if _, err := s.db.UpdateFeedFollowSettings(context.Background(), params); err != nil {
+		return fmt.Errorf("error updating follow: %v", err)
+	}
+	fmt.Printf("Updated %s\n", args[0])
+	return nil
+}
+
+// followFlags describes the settings of a follow that differ from the
+// defaults, for following's listing.
+func followFlags(follow database.GetFeedFollowsForUserRow) string {
+	var flags []string
+	if follow.Priority != 0 {
+		flags = append(flags, fmt.Sprintf("priority %d", follow.Priority))
+	}
+	if follow.Muted {
+		flags = append(flags, "muted")
+	}
+	if !follow.Notify {
+		flags = append(flags, "notifications off")
+	}
+	if len(flags) == 0 {
+		return ""
+	}
+	return " [" + strings.Join(flags, ", ") + "]"
+}
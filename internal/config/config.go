@@ -2,15 +2,136 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
+	"sort"
+	"strings"
+	"time"
 )
 
+// Config is the settings of one profile. The default profile lives at the
+// top level of the config file, where gator kept its only settings before
+// profiles existed; the others live under "profiles".
 type Config struct {
-	DBUrl           string `json:"db_url"`
-	CurrentUserName string `json:"current_user_name"`
+	DBUrl           string      `json:"db_url"`
+	CurrentUserName string      `json:"current_user_name"`
+	HTTP            HTTPConfig  `json:"http"`
+	SecretKeyFile   string      `json:"secret_key_file,omitempty"`
+	RuntimeDir      string      `json:"runtime_dir,omitempty"`
+	SMTP            *SMTPConfig `json:"smtp,omitempty"`
+	// SessionToken proves a login for users with a password; the database
+	// keeps only its hash.
+	SessionToken     string     `json:"session_token,omitempty"`
+	SessionExpiresAt *time.Time `json:"session_expires_at,omitempty"`
+
+	profile string
+}
+
+// DefaultProfile names the profile at the top level of the config file.
+const DefaultProfile = "default"
+
+// file is the layout of the config file.
+type file struct {
+	Config
+	CurrentProfile string            `json:"current_profile,omitempty"`
+	Profiles       map[string]Config `json:"profiles,omitempty"`
+}
+
+func (f *file) get(name string) (Config, bool) {
+	if name == DefaultProfile {
+		return f.Config, true
+	}
+	conf, ok := f.Profiles[name]
+	return conf, ok
+}
+
+func (f *file) set(name string, conf Config) {
+	if name == DefaultProfile {
+		f.Config = conf
+		return
+	}
+	if f.Profiles == nil {
+		f.Profiles = map[string]Config{}
+	}
+	f.Profiles[name] = conf
+}
+
+// HTTPConfig controls how feeds are fetched. Zero values mean "use the
+// default", so an old config file without an http section keeps working.
+type HTTPConfig struct {
+	ConnectTimeout Duration `json:"connect_timeout,omitempty"`
+	ReadTimeout    Duration `json:"read_timeout,omitempty"`
+	MaxBodyBytes   int64    `json:"max_body_bytes,omitempty"`
+	UserAgent      string   `json:"user_agent,omitempty"`
+	ContactURL     string   `json:"contact_url,omitempty"`
+	// HostInterval is how often a single host may be requested once its
+	// burst of HostBurst requests is used up.
+	HostInterval Duration `json:"host_interval,omitempty"`
+	HostBurst    int      `json:"host_burst,omitempty"`
+	// MinSpacing is the minimum gap between any two requests, whatever
+	// the host.
+	MinSpacing Duration `json:"min_spacing,omitempty"`
+}
+
+// SMTPConfig is the mail server that "digest --send" delivers through.
+// The password is kept out of the config file: it is read from the
+// GATOR_SMTP_PASSWORD environment variable or from PasswordFile.
+type SMTPConfig struct {
+	Host         string `json:"host"`
+	Port         int    `json:"port,omitempty"`
+	Username     string `json:"username,omitempty"`
+	PasswordFile string `json:"password_file,omitempty"`
+	From         string `json:"from"`
+	// To is the default recipient when --to is not given.
+	To string `json:"to,omitempty"`
+}
+
+// SMTPPasswordEnv names the environment variable holding the SMTP password.
+const SMTPPasswordEnv = "GATOR_SMTP_PASSWORD"
+
+// Password returns the SMTP password from the environment or, failing
+// that, the first line of PasswordFile. It is empty if neither is set.
+func (c SMTPConfig) Password() (string, error) {
+	if password, ok := os.LookupEnv(SMTPPasswordEnv); ok {
+		return password, nil
+	}
+	if c.PasswordFile == "" {
+		return "", nil
+	}
+	dat, err := os.ReadFile(c.PasswordFile)
+	if err != nil {
+		return "", err
+	}
+	password, _, _ := strings.Cut(string(dat), "\n")
+	return strings.TrimSuffix(password, "\r"), nil
+}
+
+// Duration is a time.Duration that is written to the config file in
+// human-readable form, e.g. "30s".
+type Duration struct {
+	time.Duration
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(dat []byte) error {
+	var s string
+	if err := json.Unmarshal(dat, &s); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
 }
 
 const configFileName = ".gatorconfig.json"
+const secretKeyFileName = ".gator.key"
+const runtimeDirName = ".gator"
 
 func getConfigFilePath() (string, error) {
 	home_dir, err := os.UserHomeDir()
@@ -21,37 +142,197 @@ func getConfigFilePath() (string, error) {
 	return file_path, nil
 }
 
+// SecretKeyPath returns the file holding the key used to encrypt feed
+// credentials, ~/.gator.key unless the config file says otherwise.
+func (c *Config) SecretKeyPath() (string, error) {
+	if c.SecretKeyFile != "" {
+		return c.SecretKeyFile, nil
+	}
+	home_dir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return home_dir + "/" + secretKeyFileName, nil
+}
+
+// RuntimeDirPath returns the directory holding the daemon's pidfile,
+// control socket and log, ~/.gator unless the config file says otherwise.
+// Other profiles get a directory of their own under it, so that each can
+// run a daemon.
+func (c *Config) RuntimeDirPath() (string, error) {
+	if c.RuntimeDir != "" {
+		return c.RuntimeDir, nil
+	}
+	home_dir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	if c.Profile() != DefaultProfile {
+		return home_dir + "/" + runtimeDirName + "/profiles/" + c.profile, nil
+	}
+	return home_dir + "/" + runtimeDirName, nil
+}
+
+// Profile returns the name of the profile c was read from.
+func (c *Config) Profile() string {
+	if c.profile == "" {
+		return DefaultProfile
+	}
+	return c.profile
+}
+
+// SetUser changes the current user. Clearing it also clears the session.
 func (c *Config) SetUser(username string) error {
 	c.CurrentUserName = username
-	dat, err := json.Marshal(c)
+	if username == "" {
+		c.SessionToken = ""
+		c.SessionExpiresAt = nil
+	}
+	return c.write()
+}
+
+// SetSession records a login: the user and, for users with a password, the
+// session token and when it expires.
+func (c *Config) SetSession(username, token string, expiresAt time.Time) error {
+	c.CurrentUserName = username
+	c.SessionToken = token
+	c.SessionExpiresAt = nil
+	if token != "" {
+		c.SessionExpiresAt = &expiresAt
+	}
+	return c.write()
+}
+
+// write saves c to its profile in the config file, leaving the other
+// profiles as they are.
+func (c *Config) write() error {
+	f, err := readFile()
 	if err != nil {
 		return err
 	}
-	path, err := getConfigFilePath()
+	f.set(c.Profile(), *c)
+	return writeFile(f)
+}
+
+// Read returns the settings of the named profile, or of the current profile
+// when name is empty.
+func Read(name string) (Config, error) {
+	f, err := readFile()
+	if err != nil {
+		return Config{}, err
+	}
+	if name == "" {
+		name = f.CurrentProfile
+	}
+	if name == "" {
+		name = DefaultProfile
+	}
+	conf, ok := f.get(name)
+	if !ok {
+		return Config{}, fmt.Errorf("no profile named %s", name)
+	}
+	conf.profile = name
+	return conf, nil
+}
+
+// Profiles lists the profiles in the config file, sorted, along with the
+// current one.
+func Profiles() (names []string, current string, err error) {
+	f, err := readFile()
 	if err != nil {
+		return nil, "", err
+	}
+	names = []string{DefaultProfile}
+	for name := range f.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	current = f.CurrentProfile
+	if current == "" {
+		current = DefaultProfile
+	}
+	return names, current, nil
+}
+
+// AddProfile adds a profile that connects to dbURL, with the rest of its
+// settings copied from c. Logins are not copied.
+func (c *Config) AddProfile(name, dbURL string) error {
+	if err := checkProfileName(name); err != nil {
 		return err
 	}
-	err = os.WriteFile(path, dat, 0644)
+	f, err := readFile()
 	if err != nil {
 		return err
 	}
+	if _, ok := f.get(name); ok {
+		return fmt.Errorf("profile %s already exists", name)
+	}
+	conf := *c
+	conf.DBUrl = dbURL
+	conf.CurrentUserName = ""
+	conf.SessionToken = ""
+	conf.SessionExpiresAt = nil
+	conf.RuntimeDir = ""
+	f.set(name, conf)
+	return writeFile(f)
+}
+
+// UseProfile makes name the current profile.
+func UseProfile(name string) error {
+	f, err := readFile()
+	if err != nil {
+		return err
+	}
+	if _, ok := f.get(name); !ok {
+		return fmt.Errorf("no profile named %s", name)
+	}
+	f.CurrentProfile = name
+	if name == DefaultProfile {
+		f.CurrentProfile = ""
+	}
+	return writeFile(f)
+}
+
+// checkProfileName refuses names that would not make a directory of their
+// own under the runtime directory.
+func checkProfileName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\\") {
+		return fmt.Errorf("invalid profile name %q", name)
+	}
 	return nil
 }
 
-func Read() (Config, error) {
-	conf := Config{}
+func readFile() (file, error) {
+	f := file{}
 	path, err := getConfigFilePath()
 	if err != nil {
-		return Config{}, err
+		return file{}, err
 	}
 	dat, err := os.ReadFile(path)
 	if err != nil {
-		return Config{}, err
+		return file{}, err
 	}
-	err = json.Unmarshal(dat, &conf)
+	err = json.Unmarshal(dat, &f)
 	if err != nil {
-		return Config{}, err
+		return file{}, err
 	}
-	return conf, nil
+	return f, nil
+}
 
+// writeFile saves the config file. It can hold session tokens, so only the
+// owner may read it.
+func writeFile(f file) error {
+	dat, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	path, err := getConfigFilePath()
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(path, dat, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Chmod(path, 0600)
 }
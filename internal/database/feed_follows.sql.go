@@ -7,11 +7,30 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+const addFeedFollowTag = `-- name: AddFeedFollowTag :exec
+INSERT INTO feed_follow_tags
+    (feed_follow_id, tag)
+VALUES
+    ($1, $2)
+ON CONFLICT DO NOTHING
+`
+
+type AddFeedFollowTagParams struct {
+	FeedFollowID uuid.UUID
+	Tag          string
+}
+
+func (q *Queries) AddFeedFollowTag(ctx context.Context, arg AddFeedFollowTagParams) error {
+	_, err := q.db.ExecContext(ctx, addFeedFollowTag, arg.FeedFollowID, arg.Tag)
+	return err
+}
+
 const createFeedFollow = `-- name: CreateFeedFollow :one
 WITH new_follow AS (
 INSERT INTO feed_follows
@@ -22,7 +41,7 @@ VALUES
     (
         $1, $2, $3, $4, $5
     )
-RETURNING id, created_at, updated_at, user_id, feed_id
+RETURNING id, created_at, updated_at, user_id, feed_id, custom_title, priority, muted, notify
 )
 SELECT nf.id, nf.created_at, nf.updated_at, nf.user_id, nf.feed_id, u.name AS follower, f.name as following
 FROM new_follow nf JOIN users u ON nf.user_id = u.id JOIN feeds f ON nf.feed_id = f.id
@@ -83,18 +102,102 @@ func (q *Queries) DeleteFeedFollow(ctx context.Context, arg DeleteFeedFollowPara
 	return err
 }
 
+const deleteFeedFollowsWithoutAccess = `-- name: DeleteFeedFollowsWithoutAccess :execrows
+DELETE FROM feed_follows ff
+USING feeds f
+WHERE ff.feed_id = f.id
+    AND f.id = $1
+    AND f.visibility <> 'public'
+    AND ff.user_id <> f.user_id
+    AND NOT (f.visibility = 'shared' AND EXISTS (SELECT 1 FROM feed_shares fs WHERE fs.feed_id = f.id AND fs.user_id = ff.user_id))
+`
+
+// Unfollows a feed for everyone who can no longer see it.
+func (q *Queries) DeleteFeedFollowsWithoutAccess(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteFeedFollowsWithoutAccess, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getFeedFollow = `-- name: GetFeedFollow :one
+SELECT id, created_at, updated_at, user_id, feed_id, custom_title, priority, muted, notify
+FROM feed_follows
+WHERE user_id = $1 AND feed_id = $2
+`
+
+type GetFeedFollowParams struct {
+	UserID uuid.UUID
+	FeedID uuid.UUID
+}
+
+func (q *Queries) GetFeedFollow(ctx context.Context, arg GetFeedFollowParams) (FeedFollow, error) {
+	row := q.db.QueryRowContext(ctx, getFeedFollow, arg.UserID, arg.FeedID)
+	var i FeedFollow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.FeedID,
+		&i.CustomTitle,
+		&i.Priority,
+		&i.Muted,
+		&i.Notify,
+	)
+	return i, err
+}
+
+const getFeedFollowTagsForUser = `-- name: GetFeedFollowTagsForUser :many
+SELECT fft.feed_follow_id, fft.tag
+FROM feed_follow_tags fft
+    JOIN feed_follows ff ON ff.id = fft.feed_follow_id
+WHERE ff.user_id = $1
+ORDER BY fft.tag
+`
+
+func (q *Queries) GetFeedFollowTagsForUser(ctx context.Context, userID uuid.UUID) ([]FeedFollowTag, error) {
+	rows, err := q.db.QueryContext(ctx, getFeedFollowTagsForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []FeedFollowTag
+	for rows.Next() {
+		var i FeedFollowTag
+		if err := rows.Scan(&i.FeedFollowID, &i.Tag); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getFeedFollowsForUser = `-- name: GetFeedFollowsForUser :many
-SELECT ff.id, u.name AS follower, f.name AS feed
+SELECT ff.id, u.name AS follower, COALESCE(ff.custom_title, f.name)::text AS feed, f.url,
+    ff.priority, ff.muted, ff.notify
 FROM feed_follows ff
     JOIN users u ON ff.user_id = u.id
     JOIN feeds f ON ff.feed_id = f.id
 WHERE ff.user_id = $1
+ORDER BY ff.priority DESC, feed
 `
 
 type GetFeedFollowsForUserRow struct {
 	ID       uuid.UUID
 	Follower string
 	Feed     string
+	Url      string
+	Priority int32
+	Muted    bool
+	Notify   bool
 }
 
 func (q *Queries) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) ([]GetFeedFollowsForUserRow, error) {
@@ -106,7 +209,15 @@ func (q *Queries) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) (
 	var items []GetFeedFollowsForUserRow
 	for rows.Next() {
 		var i GetFeedFollowsForUserRow
-		if err := rows.Scan(&i.ID, &i.Follower, &i.Feed); err != nil {
+		if err := rows.Scan(
+			&i.ID,
+			&i.Follower,
+			&i.Feed,
+			&i.Url,
+			&i.Priority,
+			&i.Muted,
+			&i.Notify,
+		); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -119,3 +230,61 @@ func (q *Queries) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) (
 	}
 	return items, nil
 }
+
+const removeFeedFollowTag = `-- name: RemoveFeedFollowTag :execrows
+DELETE FROM feed_follow_tags
+WHERE feed_follow_id = $1 AND tag = $2
+`
+
+type RemoveFeedFollowTagParams struct {
+	FeedFollowID uuid.UUID
+	Tag          string
+}
+
+func (q *Queries) RemoveFeedFollowTag(ctx context.Context, arg RemoveFeedFollowTagParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, removeFeedFollowTag, arg.FeedFollowID, arg.Tag)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateFeedFollowSettings = `-- name: UpdateFeedFollowSettings :one
+UPDATE feed_follows
+SET custom_title = $1, priority = $2, muted = $3, notify = $4, updated_at = $5
+WHERE id = $6
+RETURNING id, created_at, updated_at, user_id, feed_id, custom_title, priority, muted, notify
+`
+
+type UpdateFeedFollowSettingsParams struct {
+	CustomTitle sql.NullString
+	Priority    int32
+	Muted       bool
+	Notify      bool
+	UpdatedAt   time.Time
+	ID          uuid.UUID
+}
+
+func (q *Queries) UpdateFeedFollowSettings(ctx context.Context, arg UpdateFeedFollowSettingsParams) (FeedFollow, error) {
+	row := q.db.QueryRowContext(ctx, updateFeedFollowSettings,
+		arg.CustomTitle,
+		arg.Priority,
+		arg.Muted,
+		arg.Notify,
+		arg.UpdatedAt,
+		arg.ID,
+	)
+	var i FeedFollow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.FeedID,
+		&i.CustomTitle,
+		&i.Priority,
+		&i.Muted,
+		&i.Notify,
+	)
+	return i, err
+}
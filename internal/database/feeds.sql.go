@@ -13,21 +13,138 @@ import (
 	"github.com/google/uuid"
 )
 
+const claimFeed = `-- name: ClaimFeed :one
+UPDATE feeds
+SET last_fetched_at = $1::timestamp,
+    updated_at = $1::timestamp,
+    lease_expires_at = $2::timestamp
+WHERE id = $3
+    AND (lease_expires_at IS NULL OR lease_expires_at <= $1::timestamp)
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, next_fetch_at, last_status, last_error, lease_expires_at, visibility
+`
+
+type ClaimFeedParams struct {
+	Now            time.Time
+	LeaseExpiresAt time.Time
+	ID             uuid.UUID
+}
+
+func (q *Queries) ClaimFeed(ctx context.Context, arg ClaimFeedParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, claimFeed, arg.Now, arg.LeaseExpiresAt, arg.ID)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.NextFetchAt,
+		&i.LastStatus,
+		&i.LastError,
+		&i.LeaseExpiresAt,
+		&i.Visibility,
+	)
+	return i, err
+}
+
+const claimNextFeedsToFetch = `-- name: ClaimNextFeedsToFetch :many
+UPDATE feeds
+SET last_fetched_at = $1::timestamp,
+    updated_at = $1::timestamp,
+    lease_expires_at = $2::timestamp
+WHERE id IN (
+    SELECT id
+    FROM feeds
+    WHERE (next_fetch_at IS NULL OR next_fetch_at <= $1::timestamp)
+        AND (lease_expires_at IS NULL OR lease_expires_at <= $1::timestamp)
+        AND (last_fetched_at IS NULL OR last_fetched_at < $3::timestamp)
+    ORDER BY last_fetched_at ASC NULLS FIRST
+    LIMIT $4
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, next_fetch_at, last_status, last_error, lease_expires_at, visibility
+`
+
+type ClaimNextFeedsToFetchParams struct {
+	Now            time.Time
+	LeaseExpiresAt time.Time
+	FetchedBefore  time.Time
+	Limit          int32
+}
+
+func (q *Queries) ClaimNextFeedsToFetch(ctx context.Context, arg ClaimNextFeedsToFetchParams) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, claimNextFeedsToFetch,
+		arg.Now,
+		arg.LeaseExpiresAt,
+		arg.FetchedBefore,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Feed
+	for rows.Next() {
+		var i Feed
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.Url,
+			&i.UserID,
+			&i.LastFetchedAt,
+			&i.NextFetchAt,
+			&i.LastStatus,
+			&i.LastError,
+			&i.LeaseExpiresAt,
+			&i.Visibility,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const countFeedsDue = `-- name: CountFeedsDue :one
+SELECT COUNT(*)
+FROM feeds
+WHERE (next_fetch_at IS NULL OR next_fetch_at <= $1::timestamp)
+    AND (lease_expires_at IS NULL OR lease_expires_at <= $1::timestamp)
+`
+
+func (q *Queries) CountFeedsDue(ctx context.Context, now time.Time) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countFeedsDue, now)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createFeed = `-- name: CreateFeed :one
 INSERT INTO feeds
-    (id, created_at, updated_at, name, url, user_id)
+    (id, created_at, updated_at, name, url, user_id, visibility)
 VALUES
-    ($1, $2, $3, $4, $5, $6)
-RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at
+    ($1, $2, $3, $4, $5, $6, $7)
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, next_fetch_at, last_status, last_error, lease_expires_at, visibility
 `
 
 type CreateFeedParams struct {
-	ID        uuid.UUID
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	Name      string
-	Url       string
-	UserID    uuid.UUID
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	Name       string
+	Url        string
+	UserID     uuid.UUID
+	Visibility string
 }
 
 func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, error) {
@@ -38,7 +155,89 @@ func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, e
 		arg.Name,
 		arg.Url,
 		arg.UserID,
+		arg.Visibility,
+	)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.NextFetchAt,
+		&i.LastStatus,
+		&i.LastError,
+		&i.LeaseExpiresAt,
+		&i.Visibility,
 	)
+	return i, err
+}
+
+const deleteFeed = `-- name: DeleteFeed :execrows
+DELETE FROM feeds
+WHERE id = $1
+`
+
+func (q *Queries) DeleteFeed(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteFeed, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getAllFeeds = `-- name: GetAllFeeds :many
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, next_fetch_at, last_status, last_error, lease_expires_at, visibility
+FROM feeds
+ORDER BY name
+`
+
+func (q *Queries) GetAllFeeds(ctx context.Context) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, getAllFeeds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Feed
+	for rows.Next() {
+		var i Feed
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.Url,
+			&i.UserID,
+			&i.LastFetchedAt,
+			&i.NextFetchAt,
+			&i.LastStatus,
+			&i.LastError,
+			&i.LeaseExpiresAt,
+			&i.Visibility,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFeedByID = `-- name: GetFeedByID :one
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, next_fetch_at, last_status, last_error, lease_expires_at, visibility
+FROM feeds
+WHERE id = $1
+`
+
+func (q *Queries) GetFeedByID(ctx context.Context, id uuid.UUID) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, getFeedByID, id)
 	var i Feed
 	err := row.Scan(
 		&i.ID,
@@ -48,12 +247,17 @@ func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, e
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.NextFetchAt,
+		&i.LastStatus,
+		&i.LastError,
+		&i.LeaseExpiresAt,
+		&i.Visibility,
 	)
 	return i, err
 }
 
 const getFeedByURL = `-- name: GetFeedByURL :one
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, next_fetch_at, last_status, last_error, lease_expires_at, visibility
 FROM feeds
 WHERE url = $1
 LIMIT 1
@@ -70,23 +274,33 @@ func (q *Queries) GetFeedByURL(ctx context.Context, url string) (Feed, error) {
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.NextFetchAt,
+		&i.LastStatus,
+		&i.LastError,
+		&i.LeaseExpiresAt,
+		&i.Visibility,
 	)
 	return i, err
 }
 
 const getFeeds = `-- name: GetFeeds :many
-SELECT u.name as creator, f.name as feed_name, f.url
+SELECT u.name as creator, f.name as feed_name, f.url, f.visibility
 FROM feeds f JOIN users u ON f.user_id = u.id
+WHERE f.visibility = 'public'
+    OR f.user_id = $1
+    OR EXISTS (SELECT 1 FROM feed_shares fs WHERE fs.feed_id = f.id AND fs.user_id = $1)
 `
 
 type GetFeedsRow struct {
-	Creator  string
-	FeedName string
-	Url      string
+	Creator    string
+	FeedName   string
+	Url        string
+	Visibility string
 }
 
-func (q *Queries) GetFeeds(ctx context.Context) ([]GetFeedsRow, error) {
-	rows, err := q.db.QueryContext(ctx, getFeeds)
+// Lists the feeds the given user can see.
+func (q *Queries) GetFeeds(ctx context.Context, userID uuid.UUID) ([]GetFeedsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getFeeds, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -94,7 +308,12 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]GetFeedsRow, error) {
 	var items []GetFeedsRow
 	for rows.Next() {
 		var i GetFeedsRow
-		if err := rows.Scan(&i.Creator, &i.FeedName, &i.Url); err != nil {
+		if err := rows.Scan(
+			&i.Creator,
+			&i.FeedName,
+			&i.Url,
+			&i.Visibility,
+		); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -108,58 +327,134 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]GetFeedsRow, error) {
 	return items, nil
 }
 
-const getNextFeedsToFetch = `-- name: GetNextFeedsToFetch :many
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at
-FROM feeds
-ORDER BY last_fetched_at ASC NULLS FIRST
-LIMIT
-$1
+const renameFeed = `-- name: RenameFeed :one
+UPDATE feeds
+SET name = $1, updated_at = $2
+WHERE id = $3
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, next_fetch_at, last_status, last_error, lease_expires_at, visibility
+`
+
+type RenameFeedParams struct {
+	Name      string
+	UpdatedAt time.Time
+	ID        uuid.UUID
+}
+
+func (q *Queries) RenameFeed(ctx context.Context, arg RenameFeedParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, renameFeed, arg.Name, arg.UpdatedAt, arg.ID)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.NextFetchAt,
+		&i.LastStatus,
+		&i.LastError,
+		&i.LeaseExpiresAt,
+		&i.Visibility,
+	)
+	return i, err
+}
+
+const setFeedStatus = `-- name: SetFeedStatus :execrows
+UPDATE feeds
+SET last_status = $1, last_error = $2, next_fetch_at = $3, updated_at = $4, lease_expires_at = NULL
+WHERE id = $5 AND lease_expires_at = $6
 `
 
-func (q *Queries) GetNextFeedsToFetch(ctx context.Context, limit int32) ([]Feed, error) {
-	rows, err := q.db.QueryContext(ctx, getNextFeedsToFetch, limit)
+type SetFeedStatusParams struct {
+	LastStatus     sql.NullString
+	LastError      sql.NullString
+	NextFetchAt    sql.NullTime
+	UpdatedAt      time.Time
+	ID             uuid.UUID
+	LeaseExpiresAt sql.NullTime
+}
+
+// Only the holder of the lease can record a status: lease_expires_at must
+// still be the value it set when claiming the feed.
+func (q *Queries) SetFeedStatus(ctx context.Context, arg SetFeedStatusParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setFeedStatus,
+		arg.LastStatus,
+		arg.LastError,
+		arg.NextFetchAt,
+		arg.UpdatedAt,
+		arg.ID,
+		arg.LeaseExpiresAt,
+	)
 	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	var items []Feed
-	for rows.Next() {
-		var i Feed
-		if err := rows.Scan(
-			&i.ID,
-			&i.CreatedAt,
-			&i.UpdatedAt,
-			&i.Name,
-			&i.Url,
-			&i.UserID,
-			&i.LastFetchedAt,
-		); err != nil {
-			return nil, err
-		}
-		items = append(items, i)
-	}
-	if err := rows.Close(); err != nil {
-		return nil, err
+		return 0, err
 	}
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
-	return items, nil
+	return result.RowsAffected()
+}
+
+const setFeedURL = `-- name: SetFeedURL :one
+UPDATE feeds
+SET url = $1, updated_at = $2, next_fetch_at = NULL, last_status = NULL, last_error = NULL
+WHERE id = $3
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, next_fetch_at, last_status, last_error, lease_expires_at, visibility
+`
+
+type SetFeedURLParams struct {
+	Url       string
+	UpdatedAt time.Time
+	ID        uuid.UUID
+}
+
+// The feed is fetched again on the next scrape, from its new address.
+func (q *Queries) SetFeedURL(ctx context.Context, arg SetFeedURLParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, setFeedURL, arg.Url, arg.UpdatedAt, arg.ID)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.NextFetchAt,
+		&i.LastStatus,
+		&i.LastError,
+		&i.LeaseExpiresAt,
+		&i.Visibility,
+	)
+	return i, err
 }
 
-const markFeedFetched = `-- name: MarkFeedFetched :exec
+const setFeedVisibility = `-- name: SetFeedVisibility :one
 UPDATE feeds
-SET last_fetched_at = $1, updated_at = $2
+SET visibility = $1, updated_at = $2
 WHERE id = $3
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, next_fetch_at, last_status, last_error, lease_expires_at, visibility
 `
 
-type MarkFeedFetchedParams struct {
-	LastFetchedAt sql.NullTime
-	UpdatedAt     time.Time
-	ID            uuid.UUID
+type SetFeedVisibilityParams struct {
+	Visibility string
+	UpdatedAt  time.Time
+	ID         uuid.UUID
 }
 
-func (q *Queries) MarkFeedFetched(ctx context.Context, arg MarkFeedFetchedParams) error {
-	_, err := q.db.ExecContext(ctx, markFeedFetched, arg.LastFetchedAt, arg.UpdatedAt, arg.ID)
-	return err
+func (q *Queries) SetFeedVisibility(ctx context.Context, arg SetFeedVisibilityParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, setFeedVisibility, arg.Visibility, arg.UpdatedAt, arg.ID)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.NextFetchAt,
+		&i.LastStatus,
+		&i.LastError,
+		&i.LeaseExpiresAt,
+		&i.Visibility,
+	)
+	return i, err
 }
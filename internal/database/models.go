@@ -12,21 +12,48 @@ import (
 )
 
 type Feed struct {
-	ID            uuid.UUID
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
-	Name          string
-	Url           string
-	UserID        uuid.UUID
-	LastFetchedAt sql.NullTime
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	Name           string
+	Url            string
+	UserID         uuid.UUID
+	LastFetchedAt  sql.NullTime
+	NextFetchAt    sql.NullTime
+	LastStatus     sql.NullString
+	LastError      sql.NullString
+	LeaseExpiresAt sql.NullTime
+	Visibility     string
 }
 
-type FeedFollow struct {
-	ID        uuid.UUID
+type FeedCredential struct {
+	FeedID    uuid.UUID
 	CreatedAt time.Time
 	UpdatedAt time.Time
-	UserID    uuid.UUID
+	Secret    []byte
+}
+
+type FeedFollow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	UserID      uuid.UUID
+	FeedID      uuid.UUID
+	CustomTitle sql.NullString
+	Priority    int32
+	Muted       bool
+	Notify      bool
+}
+
+type FeedFollowTag struct {
+	FeedFollowID uuid.UUID
+	Tag          string
+}
+
+type FeedShare struct {
 	FeedID    uuid.UUID
+	UserID    uuid.UUID
+	CreatedAt time.Time
 }
 
 type Post struct {
@@ -38,11 +65,91 @@ type Post struct {
 	Description sql.NullString
 	PublishedAt sql.NullTime
 	FeedID      uuid.UUID
+	Author      sql.NullString
+	Content     sql.NullString
+	Duration    sql.NullString
+	Episode     sql.NullString
+	ImageUrl    sql.NullString
+}
+
+type PostCategory struct {
+	PostID uuid.UUID
+	Name   string
+}
+
+type PostEnclosure struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	PostID    uuid.UUID
+	Url       string
+	MimeType  sql.NullString
+	Length    sql.NullInt64
+}
+
+type PostRead struct {
+	UserID uuid.UUID
+	PostID uuid.UUID
+	ReadAt time.Time
+}
+
+type Rule struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	UserID       uuid.UUID
+	FeedID       uuid.NullUUID
+	TitleMatches string
+	Action       string
+	Tag          sql.NullString
+}
+
+type Session struct {
+	TokenHash []byte
+	UserID    uuid.UUID
+	CreatedAt time.Time
+	ExpiresAt time.Time
 }
 
 type User struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Name         string
+	PasswordHash sql.NullString
+	IsAdmin      bool
+}
+
+type UserPostState struct {
+	UserID  uuid.UUID
+	PostID  uuid.UUID
+	Hidden  bool
+	Starred bool
+}
+
+type UserPostTag struct {
+	UserID uuid.UUID
+	PostID uuid.UUID
+	Tag    string
+}
+
+type Webhook struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
 	UpdatedAt time.Time
-	Name      string
+	UserID    uuid.UUID
+	Url       string
+	FeedID    uuid.NullUUID
+	Match     sql.NullString
+	Secret    []byte
+}
+
+type WebhookDelivery struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	WebhookID  uuid.UUID
+	PostID     uuid.UUID
+	Attempts   int32
+	StatusCode sql.NullInt32
+	Error      sql.NullString
+	Delivered  bool
 }
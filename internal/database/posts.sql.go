@@ -11,18 +11,20 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createPost = `-- name: CreatePost :one
 INSERT INTO posts
     (
-    id, created_at, updated_at, title, url, description, published_at, feed_id
+    id, created_at, updated_at, title, url, description, published_at, feed_id, author, content,
+    duration, episode, image_url
     )
 VALUES
     (
-        $1, $2, $3, $4, $5, $6, $7, $8
+        $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13
     )
-RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id
+RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id, author, content, duration, episode, image_url
 `
 
 type CreatePostParams struct {
@@ -34,6 +36,11 @@ type CreatePostParams struct {
 	Description sql.NullString
 	PublishedAt sql.NullTime
 	FeedID      uuid.UUID
+	Author      sql.NullString
+	Content     sql.NullString
+	Duration    sql.NullString
+	Episode     sql.NullString
+	ImageUrl    sql.NullString
 }
 
 func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, error) {
@@ -46,6 +53,11 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		arg.Description,
 		arg.PublishedAt,
 		arg.FeedID,
+		arg.Author,
+		arg.Content,
+		arg.Duration,
+		arg.Episode,
+		arg.ImageUrl,
 	)
 	var i Post
 	err := row.Scan(
@@ -57,33 +69,295 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		&i.Description,
 		&i.PublishedAt,
 		&i.FeedID,
+		&i.Author,
+		&i.Content,
+		&i.Duration,
+		&i.Episode,
+		&i.ImageUrl,
+	)
+	return i, err
+}
+
+const createPostCategory = `-- name: CreatePostCategory :exec
+INSERT INTO post_categories
+    (post_id, name)
+VALUES
+    ($1, $2)
+ON CONFLICT DO NOTHING
+`
+
+type CreatePostCategoryParams struct {
+	PostID uuid.UUID
+	Name   string
+}
+
+func (q *Queries) CreatePostCategory(ctx context.Context, arg CreatePostCategoryParams) error {
+	_, err := q.db.ExecContext(ctx, createPostCategory, arg.PostID, arg.Name)
+	return err
+}
+
+const createPostEnclosure = `-- name: CreatePostEnclosure :exec
+INSERT INTO post_enclosures
+    (id, created_at, post_id, url, mime_type, length)
+VALUES
+    ($1, $2, $3, $4, $5, $6)
+ON CONFLICT DO NOTHING
+`
+
+type CreatePostEnclosureParams struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	PostID    uuid.UUID
+	Url       string
+	MimeType  sql.NullString
+	Length    sql.NullInt64
+}
+
+func (q *Queries) CreatePostEnclosure(ctx context.Context, arg CreatePostEnclosureParams) error {
+	_, err := q.db.ExecContext(ctx, createPostEnclosure,
+		arg.ID,
+		arg.CreatedAt,
+		arg.PostID,
+		arg.Url,
+		arg.MimeType,
+		arg.Length,
+	)
+	return err
+}
+
+const deletePosts = `-- name: DeletePosts :exec
+DELETE FROM posts
+`
+
+func (q *Queries) DeletePosts(ctx context.Context) error {
+	_, err := q.db.ExecContext(ctx, deletePosts)
+	return err
+}
+
+const deletePostsInFeedsOfUser = `-- name: DeletePostsInFeedsOfUser :execrows
+DELETE FROM posts
+WHERE feed_id IN (
+    SELECT id
+    FROM feeds
+    WHERE user_id = $1
+)
+`
+
+func (q *Queries) DeletePostsInFeedsOfUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deletePostsInFeedsOfUser, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getCategoriesForPosts = `-- name: GetCategoriesForPosts :many
+SELECT post_id, name
+FROM post_categories
+WHERE post_id = ANY($1::uuid[])
+ORDER BY name
+`
+
+func (q *Queries) GetCategoriesForPosts(ctx context.Context, postIds []uuid.UUID) ([]PostCategory, error) {
+	rows, err := q.db.QueryContext(ctx, getCategoriesForPosts, pq.Array(postIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PostCategory
+	for rows.Next() {
+		var i PostCategory
+		if err := rows.Scan(&i.PostID, &i.Name); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getEnclosuresForPost = `-- name: GetEnclosuresForPost :many
+SELECT id, created_at, post_id, url, mime_type, length
+FROM post_enclosures
+WHERE post_id = $1
+`
+
+func (q *Queries) GetEnclosuresForPost(ctx context.Context, postID uuid.UUID) ([]PostEnclosure, error) {
+	rows, err := q.db.QueryContext(ctx, getEnclosuresForPost, postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PostEnclosure
+	for rows.Next() {
+		var i PostEnclosure
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.PostID,
+			&i.Url,
+			&i.MimeType,
+			&i.Length,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getEnclosuresForPosts = `-- name: GetEnclosuresForPosts :many
+SELECT id, created_at, post_id, url, mime_type, length
+FROM post_enclosures
+WHERE post_id = ANY($1::uuid[])
+`
+
+func (q *Queries) GetEnclosuresForPosts(ctx context.Context, postIds []uuid.UUID) ([]PostEnclosure, error) {
+	rows, err := q.db.QueryContext(ctx, getEnclosuresForPosts, pq.Array(postIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PostEnclosure
+	for rows.Next() {
+		var i PostEnclosure
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.PostID,
+			&i.Url,
+			&i.MimeType,
+			&i.Length,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPostByID = `-- name: GetPostByID :one
+SELECT id, created_at, updated_at, title, url, description, published_at, feed_id, author, content, duration, episode, image_url
+FROM posts
+WHERE id = $1
+`
+
+func (q *Queries) GetPostByID(ctx context.Context, id uuid.UUID) (Post, error) {
+	row := q.db.QueryRowContext(ctx, getPostByID, id)
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Title,
+		&i.Url,
+		&i.Description,
+		&i.PublishedAt,
+		&i.FeedID,
+		&i.Author,
+		&i.Content,
+		&i.Duration,
+		&i.Episode,
+		&i.ImageUrl,
 	)
 	return i, err
 }
 
 const getPostsForUser = `-- name: GetPostsForUser :many
-SELECT p.id, p.created_at, p.updated_at, p.title, p.url, p.description, p.published_at, p.feed_id
+SELECT p.id, p.created_at, p.updated_at, p.title, p.url, p.description, p.published_at, p.feed_id, p.author, p.content, p.duration, p.episode, p.image_url, COALESCE(ups.starred, FALSE)::boolean AS starred,
+    COALESCE(ff.custom_title, f.name)::text AS feed_name
 FROM posts p
     JOIN feed_follows ff ON ff.feed_id = p.feed_id
+    JOIN feeds f ON f.id = p.feed_id
+    LEFT JOIN user_post_states ups ON ups.user_id = ff.user_id AND ups.post_id = p.id
 WHERE ff.user_id = $1
-ORDER BY p.published_at DESC
-LIMIT $2
+    AND NOT ff.muted
+    AND ($2::boolean OR NOT COALESCE(ups.hidden, FALSE))
+    AND (NOT $3::boolean OR COALESCE(ups.starred, FALSE))
+    AND ($4::text = '' OR EXISTS (
+        SELECT 1
+        FROM feed_follow_tags fft
+        WHERE fft.feed_follow_id = ff.id AND (lower(fft.tag) = lower($4::text) OR starts_with(lower(fft.tag), lower($4::text) || '/'))
+    ) OR EXISTS (
+        SELECT 1
+        FROM user_post_tags upt
+        WHERE upt.user_id = ff.user_id AND upt.post_id = p.id AND lower(upt.tag) = lower($4::text)
+    ))
+    AND ($5::text = '' OR strpos(lower(p.author), lower($5::text)) > 0)
+    AND ($6::text = '' OR EXISTS (
+        SELECT 1
+        FROM post_categories pc
+        WHERE pc.post_id = p.id AND lower(pc.name) = lower($6::text)
+    ))
+ORDER BY ff.priority DESC, p.published_at DESC
+LIMIT $7
+OFFSET $8
 `
 
 type GetPostsForUserParams struct {
-	UserID uuid.UUID
-	Limit  int32
+	UserID        uuid.UUID
+	IncludeHidden bool
+	StarredOnly   bool
+	Tag           string
+	Author        string
+	Category      string
+	Limit         int32
+	Offset        int32
+}
+
+type GetPostsForUserRow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Title       string
+	Url         string
+	Description sql.NullString
+	PublishedAt sql.NullTime
+	FeedID      uuid.UUID
+	Author      sql.NullString
+	Content     sql.NullString
+	Duration    sql.NullString
+	Episode     sql.NullString
+	ImageUrl    sql.NullString
+	Starred     bool
+	FeedName    string
 }
 
-func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams) ([]Post, error) {
-	rows, err := q.db.QueryContext(ctx, getPostsForUser, arg.UserID, arg.Limit)
+func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams) ([]GetPostsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPostsForUser,
+		arg.UserID,
+		arg.IncludeHidden,
+		arg.StarredOnly,
+		arg.Tag,
+		arg.Author,
+		arg.Category,
+		arg.Limit,
+		arg.Offset,
+	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Post
+	var items []GetPostsForUserRow
 	for rows.Next() {
-		var i Post
+		var i GetPostsForUserRow
 		if err := rows.Scan(
 			&i.ID,
 			&i.CreatedAt,
@@ -93,6 +367,83 @@ func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams
 			&i.Description,
 			&i.PublishedAt,
 			&i.FeedID,
+			&i.Author,
+			&i.Content,
+			&i.Duration,
+			&i.Episode,
+			&i.ImageUrl,
+			&i.Starred,
+			&i.FeedName,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUnreadPostsForUser = `-- name: GetUnreadPostsForUser :many
+SELECT p.id, p.title, p.url, p.description, p.published_at, p.author,
+    COALESCE(ff.custom_title, f.name)::text AS feed_name, f.url AS feed_url
+FROM posts p
+    JOIN feed_follows ff ON ff.feed_id = p.feed_id
+    JOIN feeds f ON f.id = p.feed_id
+WHERE ff.user_id = $1
+    AND NOT ff.muted
+    AND p.created_at >= $2
+    AND NOT EXISTS (
+        SELECT 1
+        FROM post_reads pr
+        WHERE pr.user_id = $1 AND pr.post_id = p.id
+    )
+    AND NOT EXISTS (
+        SELECT 1
+        FROM user_post_states ups
+        WHERE ups.user_id = $1 AND ups.post_id = p.id AND ups.hidden
+    )
+ORDER BY ff.priority DESC, feed_name, f.url, p.published_at DESC
+`
+
+type GetUnreadPostsForUserParams struct {
+	UserID uuid.UUID
+	Since  time.Time
+}
+
+type GetUnreadPostsForUserRow struct {
+	ID          uuid.UUID
+	Title       string
+	Url         string
+	Description sql.NullString
+	PublishedAt sql.NullTime
+	Author      sql.NullString
+	FeedName    string
+	FeedUrl     string
+}
+
+func (q *Queries) GetUnreadPostsForUser(ctx context.Context, arg GetUnreadPostsForUserParams) ([]GetUnreadPostsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getUnreadPostsForUser, arg.UserID, arg.Since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUnreadPostsForUserRow
+	for rows.Next() {
+		var i GetUnreadPostsForUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.Author,
+			&i.FeedName,
+			&i.FeedUrl,
 		); err != nil {
 			return nil, err
 		}
@@ -106,3 +457,22 @@ func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams
 	}
 	return items, nil
 }
+
+const markPostRead = `-- name: MarkPostRead :exec
+INSERT INTO post_reads
+    (user_id, post_id, read_at)
+VALUES
+    ($1, $2, $3)
+ON CONFLICT DO NOTHING
+`
+
+type MarkPostReadParams struct {
+	UserID uuid.UUID
+	PostID uuid.UUID
+	ReadAt time.Time
+}
+
+func (q *Queries) MarkPostRead(ctx context.Context, arg MarkPostReadParams) error {
+	_, err := q.db.ExecContext(ctx, markPostRead, arg.UserID, arg.PostID, arg.ReadAt)
+	return err
+}
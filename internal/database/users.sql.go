@@ -7,6 +7,7 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
@@ -15,13 +16,13 @@ import (
 const createUser = `-- name: CreateUser :one
 INSERT INTO users
     (
-    id, created_at, updated_at, name
+    id, created_at, updated_at, name, is_admin
     )
 VALUES
     (
-        $1, $2, $3, $4
+        $1, $2, $3, $4, NOT EXISTS (SELECT 1 FROM users)
 )
-RETURNING id, created_at, updated_at, name
+RETURNING id, created_at, updated_at, name, password_hash, is_admin
 `
 
 type CreateUserParams struct {
@@ -44,10 +45,25 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Name,
+		&i.PasswordHash,
+		&i.IsAdmin,
 	)
 	return i, err
 }
 
+const deleteUser = `-- name: DeleteUser :execrows
+DELETE FROM users
+WHERE id = $1
+`
+
+func (q *Queries) DeleteUser(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const deleteUsers = `-- name: DeleteUsers :exec
 DELETE FROM users
 `
@@ -57,8 +73,29 @@ func (q *Queries) DeleteUsers(ctx context.Context) error {
 	return err
 }
 
+const getOtherUserCounts = `-- name: GetOtherUserCounts :one
+SELECT
+    COUNT(*) AS users,
+    COUNT(*) FILTER (WHERE is_admin) AS admins
+FROM users
+WHERE id <> $1
+`
+
+type GetOtherUserCountsRow struct {
+	Users  int64
+	Admins int64
+}
+
+// Counts the users other than one, and how many of them are admins.
+func (q *Queries) GetOtherUserCounts(ctx context.Context, id uuid.UUID) (GetOtherUserCountsRow, error) {
+	row := q.db.QueryRowContext(ctx, getOtherUserCounts, id)
+	var i GetOtherUserCountsRow
+	err := row.Scan(&i.Users, &i.Admins)
+	return i, err
+}
+
 const getUserByName = `-- name: GetUserByName :one
-SELECT id, created_at, updated_at, name
+SELECT id, created_at, updated_at, name, password_hash, is_admin
 FROM users
 WHERE name = $1
 LIMIT 1
@@ -72,6 +109,8 @@ func (q *Queries) GetUserByName(ctx context.Context, name string) (User, error)
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Name,
+		&i.PasswordHash,
+		&i.IsAdmin,
 	)
 	return i, err
 }
@@ -89,8 +128,28 @@ func (q *Queries) GetUserNameByID(ctx context.Context, name string) (string, err
 	return name, err
 }
 
+const getUserStats = `-- name: GetUserStats :one
+SELECT
+    (SELECT COUNT(*) FROM feeds f WHERE f.user_id = $1) AS feeds_created,
+    (SELECT COUNT(*) FROM feed_follows ff WHERE ff.user_id = $1) AS follows,
+    (SELECT COUNT(*) FROM post_reads pr WHERE pr.user_id = $1) AS posts_read
+`
+
+type GetUserStatsRow struct {
+	FeedsCreated int64
+	Follows      int64
+	PostsRead    int64
+}
+
+func (q *Queries) GetUserStats(ctx context.Context, userID uuid.UUID) (GetUserStatsRow, error) {
+	row := q.db.QueryRowContext(ctx, getUserStats, userID)
+	var i GetUserStatsRow
+	err := row.Scan(&i.FeedsCreated, &i.Follows, &i.PostsRead)
+	return i, err
+}
+
 const getUsers = `-- name: GetUsers :many
-SELECT id, created_at, updated_at, name
+SELECT id, created_at, updated_at, name, password_hash, is_admin
 FROM users
 `
 
@@ -108,6 +167,8 @@ func (q *Queries) GetUsers(ctx context.Context) ([]User, error) {
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.Name,
+			&i.PasswordHash,
+			&i.IsAdmin,
 		); err != nil {
 			return nil, err
 		}
@@ -121,3 +182,64 @@ func (q *Queries) GetUsers(ctx context.Context) ([]User, error) {
 	}
 	return items, nil
 }
+
+const renameUser = `-- name: RenameUser :one
+UPDATE users
+SET name = $1, updated_at = $2
+WHERE id = $3
+RETURNING id, created_at, updated_at, name, password_hash, is_admin
+`
+
+type RenameUserParams struct {
+	Name      string
+	UpdatedAt time.Time
+	ID        uuid.UUID
+}
+
+func (q *Queries) RenameUser(ctx context.Context, arg RenameUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, renameUser, arg.Name, arg.UpdatedAt, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.PasswordHash,
+		&i.IsAdmin,
+	)
+	return i, err
+}
+
+const setUserAdmin = `-- name: SetUserAdmin :exec
+UPDATE users
+SET is_admin = $1, updated_at = $2
+WHERE id = $3
+`
+
+type SetUserAdminParams struct {
+	IsAdmin   bool
+	UpdatedAt time.Time
+	ID        uuid.UUID
+}
+
+func (q *Queries) SetUserAdmin(ctx context.Context, arg SetUserAdminParams) error {
+	_, err := q.db.ExecContext(ctx, setUserAdmin, arg.IsAdmin, arg.UpdatedAt, arg.ID)
+	return err
+}
+
+const setUserPassword = `-- name: SetUserPassword :exec
+UPDATE users
+SET password_hash = $1, updated_at = $2
+WHERE id = $3
+`
+
+type SetUserPasswordParams struct {
+	PasswordHash sql.NullString
+	UpdatedAt    time.Time
+	ID           uuid.UUID
+}
+
+func (q *Queries) SetUserPassword(ctx context.Context, arg SetUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, setUserPassword, arg.PasswordHash, arg.UpdatedAt, arg.ID)
+	return err
+}
@@ -0,0 +1,94 @@
+// Package secrets encrypts small values, such as feed credentials, before
+// they are written to the database. The key lives in a local file that only
+// the owner can read, so a database dump alone does not reveal them.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const keySize = 32
+
+// ErrUnsealable is returned by Open when the key or additional data differ
+// from the ones the value was sealed with, or the value was tampered with.
+var ErrUnsealable = errors.New("unable to decrypt value; was it sealed with a different key?")
+
+// LoadKey reads the hex-encoded key stored at path.
+func LoadKey(path string) ([]byte, error) {
+	dat, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(dat)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid key in %s: %v", path, err)
+	}
+	if len(key) != keySize {
+		return nil, fmt.Errorf("invalid key in %s: expected %d bytes, got %d", path, keySize, len(key))
+	}
+	return key, nil
+}
+
+// LoadOrCreateKey reads the key at path, generating a new one if the file
+// does not exist yet.
+func LoadOrCreateKey(path string) ([]byte, error) {
+	key, err := LoadKey(path)
+	if err == nil || !errors.Is(err, os.ErrNotExist) {
+		return key, err
+	}
+	key = make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, []byte(hex.EncodeToString(key)+"\n"), 0600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Seal encrypts plaintext with AES-GCM, returning the nonce followed by the
+// ciphertext. The same additional data must be given to Open, so binding a
+// value to the row it is stored in stops it being copied onto another row.
+func Seal(key, plaintext, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// Open decrypts a value produced by Seal with the same additional data.
+func Open(key, sealed, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("sealed value is too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return nil, ErrUnsealable
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
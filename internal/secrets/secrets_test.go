@@ -0,0 +1,107 @@
+package secrets
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testKey(t *testing.T, dir, name string) []byte {
+	t.Helper()
+	key, err := LoadOrCreateKey(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatalf("LoadOrCreateKey returned error: %v", err)
+	}
+	return key
+}
+
+func TestSealOpen(t *testing.T) {
+	dir := t.TempDir()
+	key := testKey(t, dir, "key")
+	otherKey := testKey(t, dir, "other")
+	plaintext := []byte(`{"username":"me","password":"hunter2"}`)
+	aad := []byte("feed_credentials:1")
+
+	sealed, err := Seal(key, plaintext, aad)
+	if err != nil {
+		t.Fatalf("Seal returned error: %v", err)
+	}
+	if bytes.Contains(sealed, []byte("hunter2")) {
+		t.Fatal("sealed value contains the plaintext")
+	}
+	again, _ := Seal(key, plaintext, aad)
+	if bytes.Equal(sealed, again) {
+		t.Error("sealing twice gave the same value; the nonce is not random")
+	}
+
+	got, err := Open(key, sealed, aad)
+	if err != nil || !bytes.Equal(got, plaintext) {
+		t.Fatalf("Open = %q, %v; want the plaintext", got, err)
+	}
+
+	tampered := bytes.Clone(sealed)
+	tampered[len(tampered)-1] ^= 1
+	tests := []struct {
+		name   string
+		key    []byte
+		sealed []byte
+		aad    []byte
+	}{
+		{"another row's additional data", key, sealed, []byte("feed_credentials:2")},
+		{"another table's additional data", key, sealed, []byte("webhooks:1")},
+		{"no additional data", key, sealed, nil},
+		{"a different key", otherKey, sealed, aad},
+		{"a tampered value", key, tampered, aad},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Open(tt.key, tt.sealed, tt.aad)
+			if !errors.Is(err, ErrUnsealable) {
+				t.Errorf("Open = %q, %v; want ErrUnsealable", got, err)
+			}
+		})
+	}
+
+	unbound, _ := Seal(key, plaintext, nil)
+	if _, err := Open(key, unbound, aad); !errors.Is(err, ErrUnsealable) {
+		t.Errorf("a value sealed without additional data opened for a row: %v", err)
+	}
+	if _, err := Open(key, sealed[:5], aad); err == nil {
+		t.Error("Open accepted a value shorter than the nonce")
+	}
+}
+
+func TestLoadOrCreateKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gator.key")
+	if _, err := LoadKey(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("LoadKey of a missing file = %v, want ErrNotExist", err)
+	}
+	key := testKey(t, filepath.Dir(path), filepath.Base(path))
+	if len(key) != keySize {
+		t.Errorf("created a %d byte key, want %d", len(key), keySize)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("key file mode = %o, want 600", perm)
+	}
+	loaded, err := LoadOrCreateKey(path)
+	if err != nil || !bytes.Equal(loaded, key) {
+		t.Errorf("LoadOrCreateKey made a new key instead of reading the existing one")
+	}
+
+	for name, content := range map[string]string{
+		"not hex":   "zz\n",
+		"too short": "00112233\n",
+	} {
+		bad := filepath.Join(t.TempDir(), "bad.key")
+		os.WriteFile(bad, []byte(content), 0600)
+		if _, err := LoadOrCreateKey(bad); err == nil {
+			t.Errorf("LoadOrCreateKey accepted a key that is %s", name)
+		}
+	}
+}
@@ -2,7 +2,7 @@ package main
 
 import (
 	"database/sql"
-	"log"
+	"flag"
 	"os"
 
 	"github.com/Lanrey-waju/gator.git/internal/config"
@@ -13,45 +13,121 @@ import (
 
 func main() {
 
-	arguments := os.Args
-	if len(arguments) < 2 {
-		log.Fatalf("Not enough arguments provided")
+	// global flags come before the command name, e.g. "gator --log-level debug agg 1m"
+	globals := flag.NewFlagSet("gator", flag.ExitOnError)
+	logLevel := globals.String("log-level", "info", "minimum level to log: debug, info, warn or error")
+	logFormat := globals.String("log-format", "text", "log format: text or json")
+	profile := globals.String("profile", "", "config profile to use instead of the current one")
+	globals.Parse(os.Args[1:])
+	if err := setupLogging(*logLevel, *logFormat); err != nil {
+		fatal("invalid logging flags", "err", err)
 	}
 
-	cmd := command{name: arguments[1], arg: arguments[2:]}
+	arguments := globals.Args()
+	if len(arguments) < 1 {
+		fatal("not enough arguments provided")
+	}
+
+	cmd := command{name: arguments[0], arg: arguments[1:]}
 
-	cfg, err := config.Read()
+	cfg, err := config.Read(*profile)
 	if err != nil {
-		log.Fatalf("Error reading config: %v", err)
+		fatal("error reading config", "err", err)
 	}
 	db, err := sql.Open("postgres", cfg.DBUrl)
 	if err != nil {
-		log.Fatal("Error connecting to database")
+		fatal("error connecting to database", "err", err)
 	}
 
 	dbQueries := database.New(db)
 
-	s := state{db: dbQueries, cfg: &cfg}
+	s := state{
+		db:         dbQueries,
+		conn:       db,
+		cfg:        &cfg,
+		fetcher:    newFetcher(cfg.HTTP),
+		globalArgs: os.Args[1 : len(os.Args)-len(arguments)],
+	}
 
 	cmds := commands{registeredCommands: make(map[string]func(*state, command) error)}
 	cmds.register("login", loginHandler)
+	cmds.register("logout", logoutHandler)
 	cmds.register("register", registerHandler)
-	cmds.register("reset", resetHandler)
+	cmds.register("passwd", middlewareLoggedIn(handlerPasswd))
+	cmds.register("reset", middlewareLoggedIn(middlewareAdmin(resetHandler)))
 	cmds.register("users", getUsersHandler)
+	cmds.register("whoami", middlewareLoggedIn(handlerWhoami))
 	cmds.register("agg", aggHandler)
+	cmds.register("refresh", middlewareLoggedIn(refreshHandler))
 	cmds.register("addfeed", middlewareLoggedIn(handlerAddFeed))
-	cmds.register("feeds", feedsHandler)
+	cmds.register("feeds", middlewareLoggedIn(feedsHandler))
 	cmds.register("follow", middlewareLoggedIn(followHandler))
 	cmds.register("following", middlewareLoggedIn(followingHandler))
 	cmds.register("unfollow", middlewareLoggedIn(handlerUnfollow))
 	cmds.register("browse", middlewareLoggedIn(handlerBrowse))
+	cmds.register("download", middlewareLoggedIn(downloadHandler))
+	cmds.register("digest", middlewareLoggedIn(handlerDigest))
 
-	if err := cmds.run(&s, cmd); err != nil {
-		log.Fatalf("Error running command: %s", err)
-	}
+	userCmds := commands{registeredCommands: make(map[string]func(*state, command) error)}
+	userCmds.register("rename", middlewareLoggedIn(handlerUserRename))
+	userCmds.register("delete", middlewareLoggedIn(handlerUserDelete))
+	userCmds.register("admin", middlewareLoggedIn(middlewareAdmin(handlerUserAdmin)))
+	cmds.register("user", userCmds.dispatch)
 
-	cfg, err = config.Read()
-	if err != nil {
-		log.Fatalf("Error reading updated config: %v", err)
+	feedCmds := commands{registeredCommands: make(map[string]func(*state, command) error)}
+	feedCmds.register("set-auth", middlewareLoggedIn(handlerFeedSetAuth))
+	feedCmds.register("status", middlewareLoggedIn(handlerFeedStatus))
+	feedCmds.register("rename", middlewareLoggedIn(handlerFeedRename))
+	feedCmds.register("set-url", middlewareLoggedIn(handlerFeedSetURL))
+	feedCmds.register("delete", middlewareLoggedIn(handlerFeedDelete))
+	feedCmds.register("visibility", middlewareLoggedIn(handlerFeedVisibility))
+	feedCmds.register("share", middlewareLoggedIn(handlerFeedShare))
+	feedCmds.register("unshare", middlewareLoggedIn(handlerFeedUnshare))
+	cmds.register("feed", feedCmds.dispatch)
+
+	followsCmds := commands{registeredCommands: make(map[string]func(*state, command) error)}
+	followsCmds.register("set", middlewareLoggedIn(handlerFollowSet))
+	cmds.register("follows", followsCmds.dispatch)
+
+	webhookCmds := commands{registeredCommands: make(map[string]func(*state, command) error)}
+	webhookCmds.register("add", middlewareLoggedIn(handlerWebhookAdd))
+	webhookCmds.register("list", middlewareLoggedIn(handlerWebhookList))
+	webhookCmds.register("remove", middlewareLoggedIn(handlerWebhookRemove))
+	webhookCmds.register("log", middlewareLoggedIn(handlerWebhookLog))
+	cmds.register("webhook", webhookCmds.dispatch)
+
+	tagCmds := commands{registeredCommands: make(map[string]func(*state, command) error)}
+	tagCmds.register("add", middlewareLoggedIn(handlerTagAdd))
+	tagCmds.register("remove", middlewareLoggedIn(handlerTagRemove))
+	cmds.register("tag", tagCmds.dispatch)
+
+	opmlCmds := commands{registeredCommands: make(map[string]func(*state, command) error)}
+	opmlCmds.register("import", middlewareLoggedIn(handlerOPMLImport))
+	opmlCmds.register("export", middlewareLoggedIn(handlerOPMLExport))
+	cmds.register("opml", opmlCmds.dispatch)
+
+	ruleCmds := commands{registeredCommands: make(map[string]func(*state, command) error)}
+	ruleCmds.register("add", middlewareLoggedIn(handlerRuleAdd))
+	ruleCmds.register("list", middlewareLoggedIn(handlerRuleList))
+	ruleCmds.register("remove", middlewareLoggedIn(handlerRuleRemove))
+	ruleCmds.register("test", middlewareLoggedIn(handlerRuleTest))
+	cmds.register("rule", ruleCmds.dispatch)
+
+	daemonCmds := commands{registeredCommands: make(map[string]func(*state, command) error)}
+	daemonCmds.register("run", handlerDaemonRun)
+	daemonCmds.register("start", handlerDaemonStart)
+	daemonCmds.register("stop", handlerDaemonStop)
+	daemonCmds.register("reload", handlerDaemonReload)
+	cmds.register("daemon", daemonCmds.dispatch)
+	cmds.register("status", statusHandler)
+
+	profileCmds := commands{registeredCommands: make(map[string]func(*state, command) error)}
+	profileCmds.register("add", handlerProfileAdd)
+	profileCmds.register("use", handlerProfileUse)
+	profileCmds.register("list", handlerProfileList)
+	cmds.register("profile", profileCmds.dispatch)
+
+	if err := cmds.run(&s, cmd); err != nil {
+		fatal("error running command", "command", cmd.name, "err", err)
 	}
 }
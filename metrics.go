@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	metricsRegistry = prometheus.NewRegistry()
+
+	fetchesTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "gator_feed_fetches_total",
+		Help: "Feed fetches attempted.",
+	})
+	fetchErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gator_feed_fetch_errors_total",
+		Help: "Feed fetches that failed, by class of error.",
+	}, []string{"class"})
+	fetchDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "gator_feed_fetch_duration_seconds",
+		Help:    "Time taken to fetch and parse a feed.",
+		Buckets: prometheus.ExponentialBuckets(0.05, 2, 10),
+	})
+	bytesDownloadedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "gator_bytes_downloaded_total",
+		Help: "Response body bytes received while fetching feeds, before decompression.",
+	})
+	postsInsertedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "gator_posts_inserted_total",
+		Help: "New posts stored.",
+	})
+	postsDuplicateTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "gator_posts_duplicate_total",
+		Help: "Feed items skipped because their URL was already stored.",
+	})
+	scrapePassDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "gator_scrape_pass_duration_seconds",
+		Help:    "Time taken by a scrape pass over the feeds that are due.",
+		Buckets: prometheus.ExponentialBuckets(0.1, 2, 12),
+	})
+	webhookDeliveriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gator_webhook_deliveries_total",
+		Help: "Webhook deliveries for new posts, by result: delivered or failed.",
+	}, []string{"result"})
+)
+
+func init() {
+	metricsRegistry.MustRegister(
+		fetchesTotal,
+		fetchErrorsTotal,
+		fetchDuration,
+		bytesDownloadedTotal,
+		postsInsertedTotal,
+		postsDuplicateTotal,
+		scrapePassDuration,
+		webhookDeliveriesTotal,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// startMetricsServer serves /metrics on addr in the background. Errors
+// after the listener is open are reported but do not stop the aggregator.
+func startMetricsServer(addr string) (*http.Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("error opening metrics listener: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{}))
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			slog.Error("error serving metrics", "err", err)
+		}
+	}()
+	slog.Info("serving metrics", "addr", listener.Addr().String())
+	return server, nil
+}
+
+// observeFetch records the outcome of a single fetchFeed call.
+func observeFetch(started time.Time, err error) {
+	fetchesTotal.Inc()
+	fetchDuration.Observe(time.Since(started).Seconds())
+	if err != nil {
+		fetchErrorsTotal.WithLabelValues(classifyFetchError(err)).Inc()
+	}
+}
+
+// classifyFetchError sorts fetch failures into a few classes that are
+// worth alerting on separately.
+func classifyFetchError(err error) string {
+	var retryLater *retryLaterError
+	var netErr net.Error
+	var syntaxErr *xml.SyntaxError
+	switch {
+	case errors.As(err, &retryLater):
+		return "rate_limited"
+	case errors.Is(err, errRobotsDisallowed):
+		return "robots"
+	case errors.Is(err, errBadStatus):
+		return "http_status"
+	case errors.Is(err, errNotAFeed):
+		return "content_type"
+	case errors.Is(err, errBodyTooLarge):
+		return "too_large"
+	case errors.Is(err, context.Canceled):
+		return "cancelled"
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.As(err, &netErr):
+		return "network"
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, errCharset):
+		return "parse"
+	default:
+		return "other"
+	}
+}
+
+// countingReader adds the bytes read through it to bytesDownloadedTotal.
+type countingReader struct {
+	r io.Reader
+}
+
+func (c countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	bytesDownloadedTotal.Add(float64(n))
+	return n, err
+}
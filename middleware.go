@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/Lanrey-waju/gator.git/internal/database"
@@ -9,10 +10,27 @@ import (
 
 func middlewareLoggedIn(handler func(s *state, cmd command, user database.User) error) func(*state, command) error {
 	return func(s *state, c command) error {
+		if s.cfg.CurrentUserName == "" {
+			return errors.New("no user is logged in; run gator login <name>")
+		}
 		user, err := s.db.GetUserByName(context.Background(), s.cfg.CurrentUserName)
 		if err != nil {
 			return fmt.Errorf("error retrieving user: %v\nAre you logged in?", err)
 		}
+		if err := checkSession(context.Background(), s, user); err != nil {
+			return err
+		}
+		return handler(s, c, user)
+	}
+}
+
+// middlewareAdmin restricts a logged-in command to admins. It goes inside
+// middlewareLoggedIn, which looks up the user.
+func middlewareAdmin(handler func(s *state, cmd command, user database.User) error) func(*state, command, database.User) error {
+	return func(s *state, c command, user database.User) error {
+		if !user.IsAdmin {
+			return fmt.Errorf("only admins can do that, and %s is not one", user.Name)
+		}
 		return handler(s, c, user)
 	}
 }
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/Lanrey-waju/gator.git/internal/database"
+	"github.com/google/uuid"
+)
+
+// OPML folders map to follow tags: a feed inside the outline "tech", itself
+// inside "news", is tagged "news/tech". A feed that appears in several
+// folders gets each of their tags, so export and import round-trip.
+type opml struct {
+	XMLName xml.Name  `xml:"opml"`
+	Version string    `xml:"version,attr"`
+	Head    opmlHead  `xml:"head"`
+	Body    []outline `xml:"body>outline"`
+}
+
+type opmlHead struct {
+	Title       string `xml:"title"`
+	DateCreated string `xml:"dateCreated,omitempty"`
+}
+
+type outline struct {
+	Text     string    `xml:"text,attr"`
+	Title    string    `xml:"title,attr,omitempty"`
+	Type     string    `xml:"type,attr,omitempty"`
+	XMLURL   string    `xml:"xmlUrl,attr,omitempty"`
+	Outlines []outline `xml:"outline"`
+}
+
+// opmlFeed is a feed read from an OPML file, with the folders it was in.
+type opmlFeed struct {
+	title string
+	url   string
+	tags  []string
+}
+
+// readOPML flattens the outlines in r into feeds, merging feeds that
+// appear more than once.
+func readOPML(r io.Reader) ([]opmlFeed, error) {
+	doc := opml{}
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("error parsing OPML: %v", err)
+	}
+	var feeds []opmlFeed
+	index := map[string]int{}
+	var walk func(outlines []outline, folder []string)
+	walk = func(outlines []outline, folder []string) {
+		for _, o := range outlines {
+			name := o.Title
+			if name == "" {
+				name = o.Text
+			}
+			if o.XMLURL == "" {
+				walk(o.Outlines, append(folder[:len(folder):len(folder)], name))
+				continue
+			}
+			i, ok := index[o.XMLURL]
+			if !ok {
+				i = len(feeds)
+				index[o.XMLURL] = i
+				feeds = append(feeds, opmlFeed{title: name, url: o.XMLURL})
+			}
+			if tag := normalizeTag(joinTag(folder)); tag != "" {
+				feeds[i].tags = append(feeds[i].tags, tag)
+			}
+		}
+	}
+	walk(doc.Body, nil)
+	return feeds, nil
+}
+
+// writeOPML writes feeds as OPML, nesting each under the folders its tags
+// name.
+func writeOPML(w io.Writer, title string, feeds []opmlFeed) error {
+	root := &folderNode{}
+	for _, feed := range feeds {
+		o := outline{Text: feed.title, Title: feed.title, Type: "rss", XMLURL: feed.url}
+		if len(feed.tags) == 0 {
+			root.feeds = append(root.feeds, o)
+		}
+		for _, tag := range feed.tags {
+			node := root
+			for _, name := range splitTag(tag) {
+				node = node.child(name)
+			}
+			node.feeds = append(node.feeds, o)
+		}
+	}
+	doc := opml{
+		Version: "2.0",
+		Head:    opmlHead{Title: title, DateCreated: time.Now().UTC().Format(time.RFC1123Z)},
+		Body:    root.outlines(),
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+type folderNode struct {
+	children map[string]*folderNode
+	feeds    []outline
+}
+
+func (n *folderNode) child(name string) *folderNode {
+	if n.children == nil {
+		n.children = map[string]*folderNode{}
+	}
+	if n.children[name] == nil {
+		n.children[name] = &folderNode{}
+	}
+	return n.children[name]
+}
+
+// outlines lists the folders, alphabetically, before the feeds.
+func (n *folderNode) outlines() []outline {
+	names := make([]string, 0, len(n.children))
+	for name := range n.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var outlines []outline
+	for _, name := range names {
+		outlines = append(outlines, outline{Text: name, Title: name, Outlines: n.children[name].outlines()})
+	}
+	return append(outlines, n.feeds...)
+}
+
+func handlerOPMLExport(s *state, cmd command, user database.User) error {
+	follows, err := s.db.GetFeedFollowsForUser(context.Background(), user.ID)
+	if err != nil {
+		return fmt.Errorf("error retrieving feed follows for user %s: %v", user.Name, err)
+	}
+	tags, err := followTags(context.Background(), s, user.ID)
+	if err != nil {
+		return fmt.Errorf("error retrieving tags for user %s: %v", user.Name, err)
+	}
+	feeds := make([]opmlFeed, 0, len(follows))
+	for _, follow := range follows {
+		feeds = append(feeds, opmlFeed{title: follow.Feed, url: follow.Url, tags: tags[follow.ID]})
+	}
+
+	out := os.Stdout
+	if len(cmd.arg) > 0 {
+		if out, err = os.Create(cmd.arg[0]); err != nil {
+			return fmt.Errorf("error creating %s: %v", cmd.arg[0], err)
+		}
+		defer out.Close()
+	}
+	if err := writeOPML(out, fmt.Sprintf("Feeds followed by %s", user.Name), feeds); err != nil {
+		return fmt.Errorf("error writing OPML: %v", err)
+	}
+	if out != os.Stdout {
+		if err := out.Close(); err != nil {
+			return fmt.Errorf("error writing %s: %v", cmd.arg[0], err)
+		}
+		fmt.Printf("Exported %d feeds to %s\n", len(feeds), cmd.arg[0])
+	}
+	return nil
+}
+
+// handlerOPMLImport adds the feeds in an OPML file that gator doesn't know
+// yet, follows them, and tags each follow with the folders it was in.
+func handlerOPMLImport(s *state, cmd command, user database.User) error {
+	if len(cmd.arg) < 1 {
+		return errors.New("opml import requires one argument: file")
+	}
+	f, err := os.Open(cmd.arg[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	feeds, err := readOPML(f)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	shared, err := sharedFeeds(ctx, s, user.ID)
+	if err != nil {
+		return err
+	}
+	added, followed, skipped := 0, 0, 0
+	for _, entry := range feeds {
+		feed, err := s.db.GetFeedByURL(ctx, entry.url)
+		if err == nil && !feedVisibleTo(feed, user, shared) {
+			fmt.Printf("Skipped %s: the feed already exists and isn't shared with you\n", entry.url)
+			skipped++
+			continue
+		}
+		if err == sql.ErrNoRows {
+			name := entry.title
+			if name == "" {
+				name = entry.url
+			}
+			feed, err = s.db.CreateFeed(ctx, database.CreateFeedParams{
+				ID:         uuid.New(),
+				CreatedAt:  time.Now().UTC(),
+				UpdatedAt:  time.Now().UTC(),
+				Name:       name,
+				Url:        entry.url,
+				UserID:     user.ID,
+				Visibility: visibilityPublic,
+			})
+			added++
+		}
+		if err != nil {
+			return fmt.Errorf("error adding feed %s: %v", entry.url, err)
+		}
+
+		follow, err := s.db.GetFeedFollow(ctx, database.GetFeedFollowParams{UserID: user.ID, FeedID: feed.ID})
+		if err == sql.ErrNoRows {
+			var created database.CreateFeedFollowRow
+			created, err = s.db.CreateFeedFollow(ctx, database.CreateFeedFollowParams{
+				ID:        uuid.New(),
+				CreatedAt: time.Now().UTC(),
+				UpdatedAt: time.Now().UTC(),
+				UserID:    user.ID,
+				FeedID:    feed.ID,
+			})
+			follow.ID = created.ID
+			followed++
+		}
+		if err != nil {
+			return fmt.Errorf("error following feed %s: %v", entry.url, err)
+		}
+
+		for _, tag := range entry.tags {
+			err := s.db.AddFeedFollowTag(ctx, database.AddFeedFollowTagParams{FeedFollowID: follow.ID, Tag: tag})
+			if err != nil {
+				return fmt.Errorf("error tagging feed %s: %v", entry.url, err)
+			}
+		}
+	}
+	fmt.Printf("Imported %d feeds: %d new, %d newly followed, %d skipped\n", len(feeds)-skipped, added, followed, skipped)
+	return nil
+}
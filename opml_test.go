@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeTag(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want string
+	}{
+		{"golang", "golang"},
+		{" news / tech ", "news/tech"},
+		{"news//tech/", "news/tech"},
+		{"/", ""},
+		{`AC\/DC`, `AC\/DC`},
+		{`music/ AC\/DC `, `music/AC\/DC`},
+		{`back\\slash`, `back\\slash`},
+		{`dangling\`, "dangling"},
+	}
+	for _, tt := range tests {
+		if got := normalizeTag(tt.tag); got != tt.want {
+			t.Errorf("normalizeTag(%q) = %q, want %q", tt.tag, got, tt.want)
+		}
+	}
+}
+
+func TestSplitTagInvertsJoinTag(t *testing.T) {
+	for _, folders := range [][]string{
+		{"news"},
+		{"news", "tech"},
+		{"AC/DC"},
+		{"music", "AC/DC", "live"},
+		{`C:\feeds`, "a/b/c"},
+	} {
+		tag := joinTag(folders)
+		if got := splitTag(tag); !reflect.DeepEqual(got, folders) {
+			t.Errorf("splitTag(joinTag(%q)) = %q via %q", folders, got, tag)
+		}
+	}
+}
+
+func TestOPMLFolderWithSlashRoundTrips(t *testing.T) {
+	doc := `<?xml version="1.0"?>
+<opml version="2.0"><body>
+  <outline text="Music">
+    <outline text="AC/DC">
+      <outline text="Fan blog" type="rss" xmlUrl="https://example.com/acdc.xml"/>
+    </outline>
+  </outline>
+  <outline text="news/tech">
+    <outline text="Tech" type="rss" xmlUrl="https://example.com/tech.xml"/>
+  </outline>
+  <outline text="Loose" type="rss" xmlUrl="https://example.com/loose.xml"/>
+</body></opml>`
+	feeds, err := readOPML(strings.NewReader(doc))
+	if err != nil {
+		t.Fatalf("readOPML returned error: %v", err)
+	}
+	want := []opmlFeed{
+		{title: "Fan blog", url: "https://example.com/acdc.xml", tags: []string{`Music/AC\/DC`}},
+		{title: "Tech", url: "https://example.com/tech.xml", tags: []string{`news\/tech`}},
+		{title: "Loose", url: "https://example.com/loose.xml"},
+	}
+	if !reflect.DeepEqual(feeds, want) {
+		t.Fatalf("readOPML = %+v, want %+v", feeds, want)
+	}
+
+	var buf bytes.Buffer
+	if err := writeOPML(&buf, "test", feeds); err != nil {
+		t.Fatalf("writeOPML returned error: %v", err)
+	}
+	exported := buf.String()
+	again, err := readOPML(&buf)
+	if err != nil {
+		t.Fatalf("reading the export returned error: %v", err)
+	}
+	if !reflect.DeepEqual(again, want) {
+		t.Errorf("export read back as %+v, want %+v", again, want)
+	}
+	if !strings.Contains(exported, `text="AC/DC"`) {
+		t.Errorf("export does not keep the folder name AC/DC:\n%s", exported)
+	}
+}
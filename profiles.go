@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Lanrey-waju/gator.git/internal/config"
+)
+
+// handlerProfileAdd adds a profile for another database. It starts with the
+// current profile's settings, but nobody logged in.
+func handlerProfileAdd(s *state, cmd command) error {
+	if len(cmd.arg) < 2 {
+		return errors.New("profile add requires two arguments: name and db_url")
+	}
+	if err := s.cfg.AddProfile(cmd.arg[0], cmd.arg[1]); err != nil {
+		return err
+	}
+	fmt.Printf("Added profile %s; switch to it with gator profile use %s\n", cmd.arg[0], cmd.arg[0])
+	return nil
+}
+
+func handlerProfileUse(s *state, cmd command) error {
+	if len(cmd.arg) < 1 {
+		return errors.New("profile use requires one argument: name")
+	}
+	if err := config.UseProfile(cmd.arg[0]); err != nil {
+		return err
+	}
+	fmt.Printf("Now using profile %s\n", cmd.arg[0])
+	return nil
+}
+
+func handlerProfileList(s *state, cmd command) error {
+	names, current, err := config.Profiles()
+	if err != nil {
+		return fmt.Errorf("error reading profiles: %v", err)
+	}
+	for _, name := range names {
+		var notes []string
+		if name == current {
+			notes = append(notes, "current")
+		}
+		if name == s.cfg.Profile() && name != current {
+			notes = append(notes, "in use")
+		}
+		if len(notes) > 0 {
+			fmt.Printf("* %s (%s)\n", name, strings.Join(notes, ", "))
+		} else {
+			fmt.Printf("* %s\n", name)
+		}
+	}
+	return nil
+}
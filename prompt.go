@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// stdin is shared by every prompt. A bufio.Reader reads ahead, so a reader
+// per prompt would swallow the lines piped in for the prompts after it.
+var stdin = bufio.NewReader(os.Stdin)
+
+// stdinIsTerminal reports whether prompts can turn off echo and ask
+// questions interactively.
+var stdinIsTerminal = func() bool {
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// promptSecret asks for a value without echoing it when stdin is a
+// terminal, and reads a plain line otherwise so that scripts can pipe it in.
+func promptSecret(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	if stdinIsTerminal() {
+		dat, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", err
+		}
+		return string(dat), nil
+	}
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// confirm asks a yes/no question on the terminal. Without a terminal there
+// is nobody to answer, so destructive commands must be given --yes instead.
+func confirm(prompt string) (bool, error) {
+	if !stdinIsTerminal() {
+		return false, errors.New("stdin is not a terminal; pass --yes to confirm")
+	}
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", prompt)
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return false, err
+	}
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "y" || answer == "yes", nil
+}
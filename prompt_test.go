@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// pipeStdin makes prompts read input as if it was piped in.
+func pipeStdin(t *testing.T, input string) {
+	t.Helper()
+	oldStdin, oldIsTerminal := stdin, stdinIsTerminal
+	t.Cleanup(func() { stdin, stdinIsTerminal = oldStdin, oldIsTerminal })
+	stdin = bufio.NewReader(strings.NewReader(input))
+	stdinIsTerminal = func() bool { return false }
+}
+
+func TestPromptSecretPiped(t *testing.T) {
+	pipeStdin(t, "first secret\r\nsecond secret\nno newline")
+	for _, want := range []string{"first secret", "second secret", "no newline"} {
+		got, err := promptSecret("Secret: ")
+		if err != nil || got != want {
+			t.Fatalf("promptSecret = %q, %v; want %q", got, err, want)
+		}
+	}
+	if got, err := promptSecret("Secret: "); err == nil {
+		t.Errorf("promptSecret = %q after the input ran out, want an error", got)
+	}
+}
+
+func TestPromptNewPasswordPiped(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{"matching passwords", "correct horse\ncorrect horse\n", false},
+		{"passwords differ", "correct horse\nbattery staple\n", true},
+		{"too short", "short\nshort\n", true},
+		{"no repeat", "correct horse\n", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pipeStdin(t, tt.input)
+			hash, err := promptNewPassword()
+			if tt.wantErr {
+				if err == nil {
+					t.Error("promptNewPassword accepted the input")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("promptNewPassword returned error: %v", err)
+			}
+			if bcrypt.CompareHashAndPassword([]byte(hash), []byte("correct horse")) != nil {
+				t.Error("the hash does not match the password")
+			}
+		})
+	}
+}
+
+func TestConfirmNeedsTerminal(t *testing.T) {
+	pipeStdin(t, "yes\n")
+	if ok, err := confirm("Delete everything?"); ok || err == nil {
+		t.Errorf("confirm = %t, %v; want a refusal without a terminal", ok, err)
+	}
+}
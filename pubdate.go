@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// pubDateLayouts are tried in order after normalizeDate has removed the
+// weekday and turned any named zone into a numeric offset. Layouts without a
+// zone are interpreted as UTC.
+var pubDateLayouts = []string{
+	// RFC 822 and its many variations, as used by pubDate
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04:05 -07:00",
+	"2 Jan 2006 15:04 -0700",
+	"2 Jan 06 15:04:05 -0700",
+	"2 Jan 06 15:04 -0700",
+	"2 January 2006 15:04:05 -0700",
+	"2 January 2006 15:04 -0700",
+	"2 Jan 2006 15:04:05",
+	"2 Jan 2006 15:04",
+	"2 January 2006 15:04:05",
+	"2 Jan 2006",
+	"2 January 2006",
+	"Jan 2 2006 15:04:05 -0700",
+	"January 2 2006 15:04:05 -0700",
+	"Jan 2 2006",
+	"January 2 2006",
+	"02-Jan-06 15:04:05 -0700",
+	"Jan 2 15:04:05 -0700 2006",
+	"Jan 2 15:04:05 2006",
+
+	// ISO 8601, as used by Atom published/updated and dc:date
+	time.RFC3339Nano,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05-0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// zoneOffsets maps the zone names seen in real feeds to their offsets.
+// time.Parse accepts any abbreviation but silently treats the ones it does
+// not know about as UTC, so they are rewritten before parsing.
+var zoneOffsets = map[string]string{
+	"UT":   "+0000",
+	"UTC":  "+0000",
+	"GMT":  "+0000",
+	"Z":    "+0000",
+	"EST":  "-0500",
+	"EDT":  "-0400",
+	"CST":  "-0600",
+	"CDT":  "-0500",
+	"MST":  "-0700",
+	"MDT":  "-0600",
+	"PST":  "-0800",
+	"PDT":  "-0700",
+	"AKST": "-0900",
+	"AKDT": "-0800",
+	"HST":  "-1000",
+	"BST":  "+0100",
+	"IST":  "+0530",
+	"CET":  "+0100",
+	"CEST": "+0200",
+	"EET":  "+0200",
+	"EEST": "+0300",
+	"MSK":  "+0300",
+	"JST":  "+0900",
+	"KST":  "+0900",
+	"AEST": "+1000",
+	"AEDT": "+1100",
+	"NZST": "+1200",
+	"NZDT": "+1300",
+}
+
+var (
+	weekdayPrefix = regexp.MustCompile(`^(?i)(mon|tue|wed|thu|fri|sat|sun)[a-z]*\.?,?\s+`)
+	trailingNote  = regexp.MustCompile(`\s*\([^)]*\)$`)
+	unknownZone   = regexp.MustCompile(`\s+([A-Za-z]{1,5})$`)
+	words         = regexp.MustCompile(`[A-Za-z]{3,}`)
+)
+
+// parsePubDate returns the publication date of item, trying pubDate, Atom
+// published, dc:date and finally Atom updated.
+func parsePubDate(item RSSItem) (time.Time, error) {
+	candidates := []string{item.PubDate, item.Published, item.DCDate, item.Updated}
+	var lastErr error
+	for _, candidate := range candidates {
+		if strings.TrimSpace(candidate) == "" {
+			continue
+		}
+		t, err := parseDate(candidate)
+		if err == nil {
+			return t, nil
+		}
+		lastErr = err
+	}
+	if lastErr == nil {
+		return time.Time{}, fmt.Errorf("item %q has no publish date", item.Title)
+	}
+	return time.Time{}, lastErr
+}
+
+func parseDate(value string) (time.Time, error) {
+	normalized := normalizeDate(value)
+	for _, layout := range pubDateLayouts {
+		if t, err := time.Parse(layout, normalized); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognised date %q", value)
+}
+
+// normalizeDate strips the parts of a date that vary wildly between feeds
+// but carry no information: the weekday, comments such as "(UTC)", commas and
+// repeated whitespace. Named zones become numeric offsets, and zones we do
+// not know are dropped so the date is read as UTC rather than rejected.
+func normalizeDate(value string) string {
+	value = strings.Join(strings.Fields(value), " ")
+	value = trailingNote.ReplaceAllString(value, "")
+	value = weekdayPrefix.ReplaceAllString(value, "")
+	value = strings.ReplaceAll(value, ",", "")
+	fields := strings.Fields(value)
+	for i, field := range fields {
+		if offset, ok := zoneOffsets[strings.ToUpper(field)]; ok && i > 0 {
+			fields[i] = offset
+		}
+	}
+	value = strings.Join(fields, " ")
+	if m := unknownZone.FindStringSubmatch(value); m != nil && strings.Contains(value, ":") {
+		value = strings.TrimSuffix(value, m[0])
+	}
+	// layouts only know "Jan" and "January", not "JAN", "jan" or "Sept"
+	return words.ReplaceAllStringFunc(value, func(word string) string {
+		word = strings.ToUpper(word[:1]) + strings.ToLower(word[1:])
+		if word == "Sept" {
+			return "Sep"
+		}
+		return word
+	})
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"RFC 1123Z", "Mon, 02 Jan 2006 15:04:05 -0700", "2006-01-02T22:04:05Z"},
+		{"RFC 1123 GMT", "Tue, 10 Jun 2003 04:00:00 GMT", "2003-06-10T04:00:00Z"},
+		{"single-digit day", "Wed, 3 Jul 2024 09:15:00 +0000", "2024-07-03T09:15:00Z"},
+		{"no seconds", "Fri, 21 Nov 1997 09:55 -0600", "1997-11-21T15:55:00Z"},
+		{"two-digit year", "Sun, 14 Feb 21 18:00:00 +0100", "2021-02-14T17:00:00Z"},
+		{"named zone EST", "Thu, 01 Feb 2024 08:30:00 EST", "2024-02-01T13:30:00Z"},
+		{"named zone PDT", "Sat, 15 Jun 2024 12:00:00 PDT", "2024-06-15T19:00:00Z"},
+		{"lower-case zone", "Mon, 06 May 2024 10:00:00 cest", "2024-05-06T08:00:00Z"},
+		{"colon in offset", "Tue, 09 Apr 2024 14:00:00 +05:30", "2024-04-09T08:30:00Z"},
+		{"full weekday and month", "Wednesday, 17 January 2024 07:45:00 +0000", "2024-01-17T07:45:00Z"},
+		{"upper-case month", "Mon, 04 MAR 2024 11:00:00 GMT", "2024-03-04T11:00:00Z"},
+		{"Sept", "Fri, 13 Sept 2024 16:20:00 +0000", "2024-09-13T16:20:00Z"},
+		{"trailing comment", "Thu, 07 Mar 2024 10:00:00 +0000 (UTC)", "2024-03-07T10:00:00Z"},
+		{"extra whitespace", "  Mon,  11 Mar  2024 10:00:00   +0000 ", "2024-03-11T10:00:00Z"},
+		{"date only", "12 Mar 2024", "2024-03-12T00:00:00Z"},
+		{"US month-first", "March 18 2024", "2024-03-18T00:00:00Z"},
+		{"RFC 3339", "2024-03-19T10:11:12Z", "2024-03-19T10:11:12Z"},
+		{"RFC 3339 offset", "2024-03-19T10:11:12+02:00", "2024-03-19T08:11:12Z"},
+		{"RFC 3339 fractional", "2024-03-19T10:11:12.345678Z", "2024-03-19T10:11:12.345678Z"},
+		{"ISO no seconds", "2024-03-20T08:00+01:00", "2024-03-20T07:00:00Z"},
+		{"ISO no zone", "2024-03-21T09:30:00", "2024-03-21T09:30:00Z"},
+		{"ISO space separated", "2024-03-22 18:45:00", "2024-03-22T18:45:00Z"},
+		{"ISO date only", "2024-03-23", "2024-03-23T00:00:00Z"},
+		{"ctime", "Mon Mar 25 14:30:00 2024", "2024-03-25T14:30:00Z"},
+		// zones we have no offset for are read as UTC rather than rejected,
+		// since the date is still right to within a day
+		{"unknown zone read as UTC", "Tue, 26 Mar 2024 10:00:00 XYZT", "2024-03-26T10:00:00Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDate(tt.value)
+			if err != nil {
+				t.Fatalf("parseDate(%q) returned error: %v", tt.value, err)
+			}
+			want, _ := time.Parse(time.RFC3339Nano, tt.want)
+			if !got.Equal(want) {
+				t.Errorf("parseDate(%q) = %s, want %s", tt.value, got.Format(time.RFC3339Nano), tt.want)
+			}
+			if got.Location() != time.UTC {
+				t.Errorf("parseDate(%q) returned location %s, want UTC", tt.value, got.Location())
+			}
+		})
+	}
+}
+
+func TestParseDateRejectsGarbage(t *testing.T) {
+	for _, value := range []string{"", "yesterday", "not a date", "32 Foo 2024", "2024-13-45"} {
+		if got, err := parseDate(value); err == nil {
+			t.Errorf("parseDate(%q) = %s, want an error", value, got)
+		}
+	}
+}
+
+func TestParsePubDateFallsBack(t *testing.T) {
+	tests := []struct {
+		name string
+		item RSSItem
+		want string
+	}{
+		{"pubDate first", RSSItem{PubDate: "Mon, 01 Apr 2024 10:00:00 GMT", Updated: "2024-04-02T10:00:00Z"}, "2024-04-01T10:00:00Z"},
+		{"Atom published", RSSItem{Published: "2024-04-03T10:00:00Z", Updated: "2024-04-04T10:00:00Z"}, "2024-04-03T10:00:00Z"},
+		{"dc:date", RSSItem{DCDate: "2024-04-05T10:00:00+00:00"}, "2024-04-05T10:00:00Z"},
+		{"Atom updated", RSSItem{Updated: "2024-04-06T10:00:00Z"}, "2024-04-06T10:00:00Z"},
+		{"bad pubDate skipped", RSSItem{PubDate: "soon", Updated: "2024-04-07T10:00:00Z"}, "2024-04-07T10:00:00Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePubDate(tt.item)
+			if err != nil {
+				t.Fatalf("parsePubDate returned error: %v", err)
+			}
+			if got.Format(time.RFC3339) != tt.want {
+				t.Errorf("parsePubDate = %s, want %s", got.Format(time.RFC3339), tt.want)
+			}
+		})
+	}
+
+	if _, err := parsePubDate(RSSItem{Title: "no dates"}); err == nil {
+		t.Error("parsePubDate of an item without dates should fail")
+	}
+	if _, err := parsePubDate(RSSItem{PubDate: "whenever"}); err == nil {
+		t.Error("parsePubDate of an item with only a bad date should fail")
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// hostLimiter spaces out requests so that a burst of feeds on the same host,
+// such as several Substack newsletters, does not look like abuse. Each host
+// gets its own token bucket, and a global limiter enforces a minimum gap
+// between any two requests.
+type hostLimiter struct {
+	mu       sync.Mutex
+	hosts    map[string]*rate.Limiter
+	interval time.Duration
+	burst    int
+	global   *rate.Limiter
+}
+
+func newHostLimiter(interval time.Duration, burst int, minSpacing time.Duration) *hostLimiter {
+	return &hostLimiter{
+		hosts:    make(map[string]*rate.Limiter),
+		interval: interval,
+		burst:    burst,
+		global:   rate.NewLimiter(rate.Every(minSpacing), 1),
+	}
+}
+
+// wait blocks until a request to host may be made or ctx is done.
+func (l *hostLimiter) wait(ctx context.Context, host string) error {
+	if err := l.global.Wait(ctx); err != nil {
+		return err
+	}
+	return l.limiter(host).Wait(ctx)
+}
+
+func (l *hostLimiter) limiter(host string) *rate.Limiter {
+	host = strings.ToLower(host)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	limiter, ok := l.hosts[host]
+	if !ok {
+		limiter = rate.NewLimiter(rate.Every(l.interval), l.burst)
+		l.hosts[host] = limiter
+	}
+	return limiter
+}
+
+// reconfigure switches l to the rates of from, keeping the tokens each host
+// has already used so that a reload does not hand every host a fresh burst.
+func (l *hostLimiter) reconfigure(from *hostLimiter) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.interval = from.interval
+	l.burst = from.burst
+	for _, limiter := range l.hosts {
+		limiter.SetLimit(rate.Every(l.interval))
+		limiter.SetBurst(l.burst)
+	}
+	l.global.SetLimit(from.global.Limit())
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Lanrey-waju/gator.git/internal/config"
+	"golang.org/x/time/rate"
+)
+
+// waitTime reports how long l made a request to host wait.
+func waitTime(t *testing.T, l *hostLimiter, host string) time.Duration {
+	t.Helper()
+	started := time.Now()
+	if err := l.wait(context.Background(), host); err != nil {
+		t.Fatalf("wait(%s) returned error: %v", host, err)
+	}
+	return time.Since(started)
+}
+
+func TestHostLimiterPerHost(t *testing.T) {
+	const interval = 100 * time.Millisecond
+	l := newHostLimiter(interval, 2, time.Microsecond)
+
+	// the burst is available straight away
+	for i := 0; i < 2; i++ {
+		if d := waitTime(t, l, "substack.com"); d > interval/2 {
+			t.Errorf("request %d within the burst waited %s", i+1, d)
+		}
+	}
+	// another host has a bucket of its own
+	if d := waitTime(t, l, "example.org"); d > interval/2 {
+		t.Errorf("first request to another host waited %s", d)
+	}
+	// host names are compared without regard to case
+	if d := waitTime(t, l, "SubStack.com"); d < interval/2 {
+		t.Errorf("request past the burst waited only %s, want about %s", d, interval)
+	}
+}
+
+func TestHostLimiterGlobalSpacing(t *testing.T) {
+	const spacing = 40 * time.Millisecond
+	l := newHostLimiter(time.Millisecond, 10, spacing)
+	started := time.Now()
+	for _, host := range []string{"a.example", "b.example", "c.example", "d.example"} {
+		waitTime(t, l, host)
+	}
+	// the first request is free, the other three wait for the spacing
+	if elapsed := time.Since(started); elapsed < 3*spacing-10*time.Millisecond {
+		t.Errorf("four requests to different hosts took %s, want at least %s", elapsed, 3*spacing)
+	}
+}
+
+func TestHostLimiterWaitCancelled(t *testing.T) {
+	l := newHostLimiter(time.Hour, 1, time.Microsecond)
+	waitTime(t, l, "slow.example")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.wait(ctx, "slow.example"); err == nil {
+		t.Error("wait returned nil for a request that could not be made before the deadline")
+	}
+}
+
+func TestHostLimiterReconfigure(t *testing.T) {
+	l := newHostLimiter(time.Hour, 2, time.Microsecond)
+	waitTime(t, l, "busy.example")
+	waitTime(t, l, "busy.example")
+
+	l.reconfigure(newHostLimiter(time.Minute, 5, 2*time.Second))
+	limiter := l.limiter("busy.example")
+	if limiter.Burst() != 5 || limiter.Limit() != rate.Every(time.Minute) {
+		t.Errorf("existing host limiter has burst %d and limit %v after reconfigure", limiter.Burst(), limiter.Limit())
+	}
+	// the tokens already used stay used, so a reload is no fresh burst
+	if tokens := limiter.Tokens(); tokens > 1 {
+		t.Errorf("host has %.1f tokens after reconfigure, want the spent burst kept", tokens)
+	}
+	if fresh := l.limiter("new.example"); fresh.Burst() != 5 {
+		t.Errorf("new host limiter has burst %d, want 5", fresh.Burst())
+	}
+	if l.global.Limit() != rate.Every(2*time.Second) {
+		t.Errorf("global limit = %v after reconfigure", l.global.Limit())
+	}
+}
+
+func TestUserAgentWithContact(t *testing.T) {
+	tests := []struct {
+		name      string
+		userAgent string
+		contact   string
+		want      string
+	}{
+		{"adds the contact", "gator/1.0", "https://example.com/bot", "gator/1.0 (+https://example.com/bot)"},
+		{"keeps an existing contact", "mybot/2.0 (+https://mine.example/about)", "https://example.com/bot", "mybot/2.0 (+https://mine.example/about)"},
+		{"keeps an existing https contact", "mybot/2.0 +https://mine.example", "https://example.com/bot", "mybot/2.0 +https://mine.example"},
+		{"comment without a URL", "mybot/2.0 (Linux)", "https://example.com/bot", "mybot/2.0 (Linux) (+https://example.com/bot)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := userAgentWithContact(tt.userAgent, tt.contact); got != tt.want {
+				t.Errorf("userAgentWithContact(%q, %q) = %q, want %q", tt.userAgent, tt.contact, got, tt.want)
+			}
+		})
+	}
+
+	f := newFetcher(config.HTTPConfig{})
+	if !strings.HasPrefix(f.userAgent, defaultUserAgent+" (+") {
+		t.Errorf("default User-Agent = %q, want %s with a contact URL", f.userAgent, defaultUserAgent)
+	}
+	f = newFetcher(config.HTTPConfig{UserAgent: "reader/3", ContactURL: "https://me.example"})
+	if f.userAgent != "reader/3 (+https://me.example)" {
+		t.Errorf("configured User-Agent = %q", f.userAgent)
+	}
+}
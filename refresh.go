@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/Lanrey-waju/gator.git/internal/database"
+	"github.com/google/uuid"
+)
+
+// refreshHandler runs a single scrape pass and exits, for cron jobs and for
+// refreshing particular feeds by hand. Without arguments it fetches every
+// feed that is due; --all ignores the schedule, as do explicit URLs. Only
+// feeds user can see are named in the output.
+func refreshHandler(s *state, cmd command, user database.User) error {
+	fs := flag.NewFlagSet("refresh", flag.ContinueOnError)
+	all := fs.Bool("all", false, "refresh every feed you can see, even those that are not due")
+	urls, err := parseArgs(fs, cmd.arg)
+	if err != nil {
+		return err
+	}
+	if *all && len(urls) > 0 {
+		return errors.New("refresh takes either --all or a list of urls, not both")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	shared, err := sharedFeeds(ctx, s, user.ID)
+	if err != nil {
+		return err
+	}
+	if !*all && len(urls) == 0 {
+		return refreshDueFeeds(ctx, s, user, shared)
+	}
+
+	var feeds []database.Feed
+	if *all {
+		all, err := s.db.GetAllFeeds(ctx)
+		if err != nil {
+			return fmt.Errorf("error retrieving feeds: %v", err)
+		}
+		for _, feed := range all {
+			if feedVisibleTo(feed, user, shared) {
+				feeds = append(feeds, feed)
+			}
+		}
+	}
+	for _, url := range urls {
+		feed, err := visibleFeed(ctx, s, user, url)
+		if err != nil {
+			return err
+		}
+		feeds = append(feeds, feed)
+	}
+	if len(feeds) == 0 {
+		fmt.Println("No feeds to refresh")
+		return nil
+	}
+
+	result := scrapeResult{}
+	for _, feed := range feeds {
+		if ctx.Err() != nil {
+			break
+		}
+		claimed, ok, err := claimFeed(ctx, s, feed)
+		if err != nil {
+			return fmt.Errorf("error claiming feed %s: %v", feed.Url, err)
+		}
+		if !ok {
+			fmt.Printf("%s: skipped, another gator is fetching it\n", feed.Name)
+			continue
+		}
+		if err := refreshAndReport(ctx, s, claimed, true, &result); err != nil {
+			return err
+		}
+	}
+	return reportRefresh(ctx, result)
+}
+
+// refreshDueFeeds refreshes every feed that is due, claiming feedsPerPass
+// at a time so that feeds near the end of a long run are not held under a
+// lease the whole time. Feeds fetched during the run are not claimed again
+// even if they are due once more, such as after an error. Feeds user cannot
+// see are refreshed too, since the pass is shared work, but only counted.
+func refreshDueFeeds(ctx context.Context, s *state, user database.User, shared map[uuid.UUID]bool) error {
+	started := time.Now().UTC()
+	result := scrapeResult{}
+	for ctx.Err() == nil {
+		feeds, err := claimDueFeeds(ctx, s, feedsPerPass, started)
+		if err != nil {
+			return fmt.Errorf("error retrieving feeds: %v", err)
+		}
+		if len(feeds) == 0 {
+			break
+		}
+		for _, feed := range feeds {
+			if ctx.Err() != nil {
+				break
+			}
+			if err := refreshAndReport(ctx, s, feed, feedVisibleTo(feed, user, shared), &result); err != nil {
+				return err
+			}
+		}
+	}
+	if result.feeds == 0 && ctx.Err() == nil {
+		fmt.Println("No feeds to refresh")
+		return nil
+	}
+	return reportRefresh(ctx, result)
+}
+
+// refreshAndReport refreshes a claimed feed, adds it to result and, if show
+// is set, prints how it went.
+func refreshAndReport(ctx context.Context, s *state, feed database.Feed, show bool, result *scrapeResult) error {
+	refresh, err := refreshFeed(ctx, s, feed)
+	if err != nil {
+		return err
+	}
+	result.add(scrapeResult{feeds: 1, newPosts: refresh.newPosts})
+	if refresh.scrapeErr != nil {
+		result.failures++
+	}
+	switch {
+	case !show:
+	case refresh.scrapeErr != nil:
+		fmt.Printf("%s: failed: %v\n", feed.Name, refresh.scrapeErr)
+	default:
+		fmt.Printf("%s: %d new posts\n", feed.Name, refresh.newPosts)
+	}
+	return nil
+}
+
+func reportRefresh(ctx context.Context, result scrapeResult) error {
+	fmt.Printf("Refreshed %d feeds, %d new posts\n", result.feeds, result.newPosts)
+	if result.failures > 0 {
+		return fmt.Errorf("%d of %d feeds failed to refresh", result.failures, result.feeds)
+	}
+	return ctx.Err()
+}
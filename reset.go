@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Lanrey-waju/gator.git/internal/database"
+)
+
+const backupDirName = "backups"
+
+// resetHandler deletes data: by default every user and, through them,
+// everything else. --posts-only keeps users, feeds and follows, and --user
+// narrows either form to one user. A pg_dump backup is taken first. Only
+// admins can reset.
+func resetHandler(s *state, cmd command, user database.User) error {
+	fs := flag.NewFlagSet("reset", flag.ContinueOnError)
+	yes := fs.Bool("yes", false, "don't ask for confirmation")
+	postsOnly := fs.Bool("posts-only", false, "delete posts but keep users, feeds and follows")
+	username := fs.String("user", "", "only reset this user, or with --posts-only the posts in the feeds they added")
+	noBackup := fs.Bool("no-backup", false, "skip the pg_dump backup")
+	if _, err := parseArgs(fs, cmd.arg); err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	var what string
+	switch {
+	case *username != "" && *postsOnly:
+		what = fmt.Sprintf("the posts in every feed %s added", *username)
+	case *username != "":
+		what = fmt.Sprintf("user %s and the feeds they added, for every follower", *username)
+	case *postsOnly:
+		what = "every post"
+	default:
+		what = "every user, feed, follow and post"
+	}
+	if !*yes {
+		ok, err := confirm(fmt.Sprintf("Delete %s?", what))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New("reset cancelled")
+		}
+	}
+
+	if !*noBackup {
+		path, err := backupDatabase(ctx, s)
+		if err != nil {
+			return fmt.Errorf("error backing up database, pass --no-backup to reset anyway: %v", err)
+		}
+		fmt.Printf("Backed up the database to %s\n", path)
+	}
+
+	clearsCurrentUser := false
+	switch {
+	case *username != "":
+		target, err := s.db.GetUserByName(ctx, *username)
+		if err != nil {
+			return fmt.Errorf("error retrieving user %s: %v", *username, err)
+		}
+		if *postsOnly {
+			if _, err := s.db.DeletePostsInFeedsOfUser(ctx, target.ID); err != nil {
+				return fmt.Errorf("error deleting posts: %v", err)
+			}
+		} else {
+			if err := checkNotLastAdmin(ctx, s, target); err != nil {
+				return err
+			}
+			if _, err := s.db.DeleteUser(ctx, target.ID); err != nil {
+				return fmt.Errorf("error deleting user %s: %v", target.Name, err)
+			}
+			clearsCurrentUser = target.ID == user.ID
+		}
+	case *postsOnly:
+		if err := s.db.DeletePosts(ctx); err != nil {
+			return fmt.Errorf("error deleting posts: %v", err)
+		}
+	default:
+		if err := s.db.DeleteUsers(ctx); err != nil {
+			return fmt.Errorf("error resetting database: %v", err)
+		}
+		clearsCurrentUser = true
+	}
+	if clearsCurrentUser {
+		if err := s.cfg.SetUser(""); err != nil {
+			return fmt.Errorf("error clearing the current user: %v", err)
+		}
+	}
+	fmt.Printf("Reset successful!\n")
+	return nil
+}
+
+// backupDatabase dumps the database with pg_dump into the runtime
+// directory, returning the file it wrote.
+func backupDatabase(ctx context.Context, s *state) (string, error) {
+	dir, err := runtimePath(s, backupDirName)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, "gator-"+time.Now().UTC().Format("20060102T150405Z")+".sql")
+	env, err := pgEnv(s.cfg.DBUrl)
+	if err != nil {
+		return "", err
+	}
+	// the connection goes through the environment, since the password
+	// in an argument would be visible to every user in ps
+	dump := exec.CommandContext(ctx, "pg_dump", "--file", path)
+	dump.Env = append(os.Environ(), env...)
+	dump.Stderr = os.Stderr
+	if err := dump.Run(); err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// pgEnvNames maps connection parameters to the libpq environment variables
+// pg_dump reads them from.
+var pgEnvNames = map[string]string{
+	"host":             "PGHOST",
+	"port":             "PGPORT",
+	"user":             "PGUSER",
+	"password":         "PGPASSWORD",
+	"dbname":           "PGDATABASE",
+	"sslmode":          "PGSSLMODE",
+	"sslcert":          "PGSSLCERT",
+	"sslkey":           "PGSSLKEY",
+	"sslrootcert":      "PGSSLROOTCERT",
+	"connect_timeout":  "PGCONNECT_TIMEOUT",
+	"application_name": "PGAPPNAME",
+}
+
+// pgEnv turns a connection string, either a postgres:// URL or key=value
+// pairs, into environment variables for the PostgreSQL client tools.
+func pgEnv(dbURL string) ([]string, error) {
+	params := map[string]string{}
+	if strings.HasPrefix(dbURL, "postgres://") || strings.HasPrefix(dbURL, "postgresql://") {
+		u, err := url.Parse(dbURL)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing the database URL: %v", err)
+		}
+		params["host"] = u.Hostname()
+		params["port"] = u.Port()
+		params["user"] = u.User.Username()
+		params["password"], _ = u.User.Password()
+		params["dbname"] = strings.TrimPrefix(u.Path, "/")
+		for name, values := range u.Query() {
+			params[name] = values[len(values)-1]
+		}
+	} else {
+		for _, field := range strings.Fields(dbURL) {
+			name, value, ok := strings.Cut(field, "=")
+			if !ok {
+				return nil, errors.New("error parsing the database connection string")
+			}
+			params[name] = strings.Trim(value, "'")
+		}
+	}
+
+	var env []string
+	for name, value := range params {
+		if variable, ok := pgEnvNames[name]; ok && value != "" {
+			env = append(env, variable+"="+value)
+		}
+	}
+	sort.Strings(env)
+	return env, nil
+}
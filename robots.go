@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	robotsTTL      = 24 * time.Hour
+	robotsRetryTTL = time.Hour
+)
+
+// robotsRule is a single Allow or Disallow line from robots.txt.
+type robotsRule struct {
+	allow   bool
+	length  int
+	pattern *regexp.Regexp
+}
+
+// robotsRules are the rules of the group that applies to gator.
+type robotsRules struct {
+	rules     []robotsRule
+	expiresAt time.Time
+}
+
+// allowed reports whether path may be fetched. As in RFC 9309 the longest
+// matching rule wins, and Allow wins a tie.
+func (r *robotsRules) allowed(path string) bool {
+	best := robotsRule{allow: true, length: -1}
+	for _, rule := range r.rules {
+		if !rule.pattern.MatchString(path) {
+			continue
+		}
+		if rule.length > best.length || (rule.length == best.length && rule.allow) {
+			best = rule
+		}
+	}
+	return best.allow
+}
+
+// robotsCache keeps the parsed robots.txt of every host we have fetched from.
+type robotsCache struct {
+	mu    sync.Mutex
+	hosts map[string]*robotsRules
+}
+
+func newRobotsCache() *robotsCache {
+	return &robotsCache{hosts: make(map[string]*robotsRules)}
+}
+
+// robotsAllowed fetches robots.txt for the host of rawURL if it is not
+// already cached, and reports whether rawURL may be requested.
+func (f *fetcher) robotsAllowed(ctx context.Context, rawURL string) (bool, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false, err
+	}
+	key := strings.ToLower(u.Scheme + "://" + u.Host)
+
+	f.robots.mu.Lock()
+	rules, ok := f.robots.hosts[key]
+	f.robots.mu.Unlock()
+	if !ok || time.Now().After(rules.expiresAt) {
+		rules = f.fetchRobots(ctx, key)
+		f.robots.mu.Lock()
+		f.robots.hosts[key] = rules
+		f.robots.mu.Unlock()
+	}
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return rules.allowed(path), nil
+}
+
+// fetchRobots downloads and parses robots.txt. A missing file allows
+// everything; so does a failed request, which is retried sooner than a
+// successful one would be refreshed.
+func (f *fetcher) fetchRobots(ctx context.Context, origin string) *robotsRules {
+	req, err := f.newRequest(ctx, origin+"/robots.txt")
+	if err != nil {
+		return &robotsRules{expiresAt: time.Now().Add(robotsRetryTTL)}
+	}
+	resp, err := f.do(req)
+	if err != nil {
+		return &robotsRules{expiresAt: time.Now().Add(robotsRetryTTL)}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		ttl := robotsTTL
+		if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+			ttl = robotsRetryTTL
+		}
+		return &robotsRules{expiresAt: time.Now().Add(ttl)}
+	}
+	dat, err := f.readBody(resp)
+	if err != nil {
+		return &robotsRules{expiresAt: time.Now().Add(robotsRetryTTL)}
+	}
+	return &robotsRules{
+		rules:     parseRobots(dat, f.agentToken()),
+		expiresAt: time.Now().Add(robotsTTL),
+	}
+}
+
+// agentToken is the product name robots.txt groups are matched against,
+// "gator" for a User-Agent of "gator/1.0 (+https://...)".
+func (f *fetcher) agentToken() string {
+	token, _, _ := strings.Cut(f.userAgent, "/")
+	token, _, _ = strings.Cut(token, " ")
+	return strings.ToLower(token)
+}
+
+// parseRobots returns the rules of the group naming agent, or of the "*"
+// group if none does.
+func parseRobots(dat []byte, agent string) []robotsRule {
+	var specific, wildcard []robotsRule
+	var groupAgents []string
+	inRules := false
+	matchesUs, matchesAll := false, false
+	foundSpecific := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(dat))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		field, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		field = strings.ToLower(strings.TrimSpace(field))
+		value = strings.TrimSpace(value)
+
+		switch field {
+		case "user-agent":
+			// consecutive user-agent lines share one group of rules
+			if inRules {
+				groupAgents = nil
+				matchesUs, matchesAll = false, false
+				inRules = false
+			}
+			groupAgents = append(groupAgents, value)
+			name := strings.ToLower(value)
+			if name == "*" {
+				matchesAll = true
+			} else if name == agent {
+				matchesUs = true
+				foundSpecific = true
+			}
+		case "allow", "disallow":
+			inRules = true
+			if len(groupAgents) == 0 || value == "" {
+				continue
+			}
+			rule := robotsRule{allow: field == "allow", length: len(value), pattern: robotsPattern(value)}
+			if matchesUs {
+				specific = append(specific, rule)
+			}
+			if matchesAll {
+				wildcard = append(wildcard, rule)
+			}
+		default:
+			// sitemap, crawl-delay and unknown fields do not end a group
+		}
+	}
+	if foundSpecific {
+		return specific
+	}
+	return wildcard
+}
+
+// robotsPattern turns a robots.txt path, which may use "*" and a trailing
+// "$", into an anchored regular expression.
+func robotsPattern(path string) *regexp.Regexp {
+	anchored := strings.HasSuffix(path, "$")
+	path = strings.TrimSuffix(path, "$")
+	parts := strings.Split(path, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	expr := "^" + strings.Join(parts, ".*")
+	if anchored {
+		expr += "$"
+	}
+	return regexp.MustCompile(expr)
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRobots(t *testing.T) {
+	tests := []struct {
+		name    string
+		robots  string
+		allowed []string
+		blocked []string
+	}{
+		{
+			name:    "no rules",
+			robots:  "",
+			allowed: []string{"/", "/feed.xml"},
+		},
+		{
+			name:    "wildcard group",
+			robots:  "User-agent: *\nDisallow: /private\n",
+			allowed: []string{"/", "/feed.xml", "/public/private"},
+			blocked: []string{"/private", "/private/feed.xml", "/privateer"},
+		},
+		{
+			name: "our group wins over the wildcard",
+			robots: "User-agent: *\nDisallow: /\n\n" +
+				"User-agent: gator\nDisallow: /drafts\n",
+			allowed: []string{"/", "/feed.xml"},
+			blocked: []string{"/drafts/1"},
+		},
+		{
+			name:    "another agent's group does not apply",
+			robots:  "User-agent: otherbot\nDisallow: /\n",
+			allowed: []string{"/", "/feed.xml"},
+		},
+		{
+			name: "consecutive user-agent lines share a group",
+			robots: "User-agent: otherbot\nUser-agent: gator\nDisallow: /shared\n" +
+				"User-agent: *\nDisallow: /\n",
+			allowed: []string{"/", "/feed.xml"},
+			blocked: []string{"/shared/feed.xml"},
+		},
+		{
+			name:    "field and agent names ignore case",
+			robots:  "USER-AGENT: Gator\nDISALLOW: /Upper\n",
+			allowed: []string{"/upper"},
+			blocked: []string{"/Upper"},
+		},
+		{
+			name:    "comments are stripped",
+			robots:  "# robots for example.com\nUser-agent: * # everyone\nDisallow: /tmp # scratch\n",
+			allowed: []string{"/", "/feed.xml"},
+			blocked: []string{"/tmp/x"},
+		},
+		{
+			name:    "empty disallow allows everything",
+			robots:  "User-agent: *\nDisallow:\n",
+			allowed: []string{"/", "/anything"},
+		},
+		{
+			name:    "sitemap and crawl-delay do not end a group",
+			robots:  "User-agent: gator\nCrawl-delay: 10\nSitemap: https://example.com/sitemap.xml\nDisallow: /slow\n",
+			allowed: []string{"/"},
+			blocked: []string{"/slow"},
+		},
+		{
+			name:    "rules before any user-agent are ignored",
+			robots:  "Disallow: /\nUser-agent: *\nDisallow: /tmp\n",
+			allowed: []string{"/", "/feed.xml"},
+			blocked: []string{"/tmp"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := &robotsRules{rules: parseRobots([]byte(tt.robots), "gator")}
+			for _, path := range tt.allowed {
+				if !rules.allowed(path) {
+					t.Errorf("%s is disallowed", path)
+				}
+			}
+			for _, path := range tt.blocked {
+				if rules.allowed(path) {
+					t.Errorf("%s is allowed", path)
+				}
+			}
+		})
+	}
+}
+
+func TestRobotsLongestMatch(t *testing.T) {
+	robots := "User-agent: *\n" +
+		"Disallow: /feeds\n" +
+		"Allow: /feeds/public\n" +
+		"Disallow: /feeds/public/drafts\n" +
+		"Allow: /tie\n" +
+		"Disallow: /tie\n"
+	rules := &robotsRules{rules: parseRobots([]byte(robots), "gator")}
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/feeds", false},
+		{"/feeds/private.xml", false},
+		{"/feeds/public/rss.xml", true},
+		{"/feeds/public/drafts/1", false},
+		{"/tie", true},
+		{"/other", true},
+	}
+	for _, tt := range tests {
+		if got := rules.allowed(tt.path); got != tt.want {
+			t.Errorf("allowed(%q) = %t, want %t", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestRobotsPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"/feed", "/feed", true},
+		{"/feed", "/feed.xml", true},
+		{"/feed", "/a/feed", false},
+		{"/*.xml", "/feeds/rss.xml", true},
+		{"/*.xml", "/feeds/rss.xml?page=2", true},
+		{"/*.xml$", "/feeds/rss.xml", true},
+		{"/*.xml$", "/feeds/rss.xml?page=2", false},
+		{"/feed$", "/feed", true},
+		{"/feed$", "/feed/", false},
+		{"/a*b*c", "/a-x-b-y-c", true},
+		{"/a*b*c", "/a-x-c", false},
+		{"/file.php?id=1", "/file.php?id=1", true},
+		{"/file.php?id=1", "/filexphp?id=1", false},
+	}
+	for _, tt := range tests {
+		if got := robotsPattern(tt.pattern).MatchString(tt.path); got != tt.want {
+			t.Errorf("robotsPattern(%q) matches %q = %t, want %t", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestRobotsAllowed(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		robots  string
+		wantTTL time.Duration
+		allowed []string
+		blocked []string
+	}{
+		{
+			name:    "rules are obeyed",
+			status:  http.StatusOK,
+			robots:  "User-agent: gator\nDisallow: /private\n",
+			wantTTL: robotsTTL,
+			allowed: []string{"/feed.xml"},
+			blocked: []string{"/private/feed.xml"},
+		},
+		{
+			name:    "missing robots.txt allows everything",
+			status:  http.StatusNotFound,
+			wantTTL: robotsTTL,
+			allowed: []string{"/feed.xml", "/private/feed.xml"},
+		},
+		{
+			name:    "server error allows everything for a while",
+			status:  http.StatusServiceUnavailable,
+			wantTTL: robotsRetryTTL,
+			allowed: []string{"/feed.xml", "/private/feed.xml"},
+		},
+		{
+			name:    "rate limited allows everything for a while",
+			status:  http.StatusTooManyRequests,
+			wantTTL: robotsRetryTTL,
+			allowed: []string{"/feed.xml"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/robots.txt" {
+					t.Errorf("unexpected request for %s", r.URL.Path)
+				}
+				requests++
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.robots))
+			}))
+			defer srv.Close()
+
+			f := testFetcher()
+			for _, path := range tt.allowed {
+				if ok, err := f.robotsAllowed(context.Background(), srv.URL+path); err != nil || !ok {
+					t.Errorf("robotsAllowed(%s) = %t, %v; want allowed", path, ok, err)
+				}
+			}
+			for _, path := range tt.blocked {
+				if ok, err := f.robotsAllowed(context.Background(), srv.URL+path); err != nil || ok {
+					t.Errorf("robotsAllowed(%s) = %t, %v; want disallowed", path, ok, err)
+				}
+			}
+			if requests != 1 {
+				t.Errorf("robots.txt was requested %d times, want once", requests)
+			}
+
+			rules := f.robots.hosts[strings.ToLower(srv.URL)]
+			if rules == nil {
+				t.Fatal("robots.txt was not cached")
+			}
+			ttl := time.Until(rules.expiresAt)
+			if ttl > tt.wantTTL || ttl < tt.wantTTL-time.Minute {
+				t.Errorf("cached for %s, want %s", ttl.Round(time.Second), tt.wantTTL)
+			}
+		})
+	}
+}
+
+func TestAgentToken(t *testing.T) {
+	for userAgent, want := range map[string]string{
+		"gator/1.0 (+https://example.com)": "gator",
+		"MyReader/2.1":                     "myreader",
+		"plainbot":                         "plainbot",
+		"Some Bot/1.0":                     "some",
+	} {
+		f := &fetcher{userAgent: userAgent}
+		if got := f.agentToken(); got != want {
+			t.Errorf("agentToken for %q = %q, want %q", userAgent, got, want)
+		}
+	}
+}
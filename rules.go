@@ -0,0 +1,312 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math"
+	"regexp"
+	"time"
+
+	"github.com/Lanrey-waju/gator.git/internal/database"
+	"github.com/google/uuid"
+)
+
+// Rule actions. A rule applies its action to every post from its feed, or
+// from any feed its owner follows, whose title matches its pattern.
+const (
+	ruleHide   = "hide"
+	ruleStar   = "star"
+	ruleNotify = "notify"
+	ruleTag    = "tag"
+)
+
+// compiledRule is a rule with its pattern ready to match.
+type compiledRule struct {
+	database.Rule
+	pattern *regexp.Regexp
+}
+
+func (r compiledRule) matches(feedID uuid.UUID, title string) bool {
+	if r.FeedID.Valid && r.FeedID.UUID != feedID {
+		return false
+	}
+	return r.pattern.MatchString(title)
+}
+
+// ruleOutcome is what one user's rules decided about a post.
+type ruleOutcome struct {
+	hide   bool
+	star   bool
+	notify bool
+}
+
+// compileRules skips rules whose pattern no longer compiles, which can only
+// happen if they were written by hand.
+func compileRules(rules []database.Rule) []compiledRule {
+	compiled := make([]compiledRule, 0, len(rules))
+	for _, rule := range rules {
+		pattern, err := regexp.Compile(rule.TitleMatches)
+		if err != nil {
+			slog.Warn("skipping rule with invalid pattern", "rule_id", rule.ID, "err", err)
+			continue
+		}
+		compiled = append(compiled, compiledRule{Rule: rule, pattern: pattern})
+	}
+	return compiled
+}
+
+// evaluateRules decides what rules do to a post without storing anything,
+// so browse can show posts as rules would leave them.
+func evaluateRules(rules []compiledRule, feedID uuid.UUID, title string) map[uuid.UUID]ruleOutcome {
+	outcomes := map[uuid.UUID]ruleOutcome{}
+	for _, rule := range rules {
+		if !rule.matches(feedID, title) {
+			continue
+		}
+		outcome := outcomes[rule.UserID]
+		switch rule.Action {
+		case ruleHide:
+			outcome.hide = true
+		case ruleStar:
+			outcome.star = true
+		case ruleNotify:
+			outcome.notify = true
+		}
+		outcomes[rule.UserID] = outcome
+	}
+	return outcomes
+}
+
+// applyRules runs rules against a post and stores the hides, stars and tags
+// they call for.
+func applyRules(ctx context.Context, q *database.Queries, rules []compiledRule, feedID, postID uuid.UUID, title string) (map[uuid.UUID]ruleOutcome, error) {
+	outcomes := evaluateRules(rules, feedID, title)
+	for _, rule := range rules {
+		if !rule.matches(feedID, title) {
+			continue
+		}
+		var err error
+		switch rule.Action {
+		case ruleHide:
+			err = q.HidePost(ctx, database.HidePostParams{UserID: rule.UserID, PostID: postID})
+		case ruleStar:
+			err = q.StarPost(ctx, database.StarPostParams{UserID: rule.UserID, PostID: postID})
+		case ruleTag:
+			err = q.TagPost(ctx, database.TagPostParams{UserID: rule.UserID, PostID: postID, Tag: rule.Tag.String})
+		}
+		if err != nil {
+			return outcomes, fmt.Errorf("error applying rule %s: %v", rule.ID, err)
+		}
+	}
+	return outcomes, nil
+}
+
+// applyIngestRules evaluates every rule covering feed against posts that
+// were just stored, returning the outcomes by post and then by user for
+// notifyWebhooks. Like webhooks, rule problems are logged rather than
+// failing the feed.
+func applyIngestRules(ctx context.Context, s *state, feed database.Feed, posts []database.Post) map[uuid.UUID]map[uuid.UUID]ruleOutcome {
+	outcomes := map[uuid.UUID]map[uuid.UUID]ruleOutcome{}
+	if len(posts) == 0 {
+		return outcomes
+	}
+	rules, err := s.db.GetRulesForFeed(ctx, feed.ID)
+	if err != nil {
+		slog.Warn("error loading rules", "feed_id", feed.ID, "err", err)
+		return outcomes
+	}
+	compiled := compileRules(rules)
+	if len(compiled) == 0 {
+		return outcomes
+	}
+	for _, post := range posts {
+		outcomes[post.ID], err = applyRules(ctx, s.db, compiled, feed.ID, post.ID, post.Title)
+		if err != nil {
+			slog.Warn("error applying rules", "feed_id", feed.ID, "post_id", post.ID, "err", err)
+		}
+	}
+	return outcomes
+}
+
+// userRules loads and compiles the rules user has set up.
+func userRules(ctx context.Context, s *state, userID uuid.UUID) ([]compiledRule, error) {
+	rows, err := s.db.GetRulesForUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	rules := make([]database.Rule, 0, len(rows))
+	for _, row := range rows {
+		rules = append(rules, database.Rule{
+			ID:           row.ID,
+			CreatedAt:    row.CreatedAt,
+			UpdatedAt:    row.UpdatedAt,
+			UserID:       row.UserID,
+			FeedID:       row.FeedID,
+			TitleMatches: row.TitleMatches,
+			Action:       row.Action,
+			Tag:          row.Tag,
+		})
+	}
+	return compileRules(rules), nil
+}
+
+// ruleFlags are the flags shared by "rule add" and "rule test".
+type ruleFlags struct {
+	feedURL *string
+	pattern *string
+}
+
+func addRuleFlags(fs *flag.FlagSet) ruleFlags {
+	return ruleFlags{
+		feedURL: fs.String("feed", "", "only apply to this feed; by default every feed you follow"),
+		pattern: fs.String("title-matches", "", "regular expression matched against post titles"),
+	}
+}
+
+// rule builds the rule described by the flags, without an action.
+func (f ruleFlags) rule(s *state, user database.User) (compiledRule, error) {
+	if *f.pattern == "" {
+		return compiledRule{}, errors.New("--title-matches is required")
+	}
+	pattern, err := regexp.Compile(*f.pattern)
+	if err != nil {
+		return compiledRule{}, fmt.Errorf("invalid --title-matches pattern: %v", err)
+	}
+	rule := compiledRule{
+		Rule:    database.Rule{UserID: user.ID, TitleMatches: *f.pattern},
+		pattern: pattern,
+	}
+	if *f.feedURL != "" {
+		feed, err := visibleFeed(context.Background(), s, user, *f.feedURL)
+		if err != nil {
+			return compiledRule{}, err
+		}
+		rule.FeedID = uuid.NullUUID{UUID: feed.ID, Valid: true}
+	}
+	return rule, nil
+}
+
+func handlerRuleAdd(s *state, cmd command, user database.User) error {
+	fs := flag.NewFlagSet("rule add", flag.ContinueOnError)
+	flags := addRuleFlags(fs)
+	action := fs.String("action", "", "what to do with matching posts: hide, star, notify or tag")
+	tag := fs.String("tag", "", "tag to add, for --action tag")
+	if _, err := parseArgs(fs, cmd.arg); err != nil {
+		return err
+	}
+	rule, err := flags.rule(s, user)
+	if err != nil {
+		return err
+	}
+	switch *action {
+	case ruleHide, ruleStar, ruleNotify:
+		if *tag != "" {
+			return fmt.Errorf("--tag only applies to --action %s", ruleTag)
+		}
+	case ruleTag:
+		if *tag == "" {
+			return fmt.Errorf("--action %s needs --tag", ruleTag)
+		}
+	default:
+		return fmt.Errorf("--action must be one of hide, star, notify or tag")
+	}
+
+	created, err := s.db.CreateRule(context.Background(), database.CreateRuleParams{
+		ID:           uuid.New(),
+		CreatedAt:    time.Now().UTC(),
+		UpdatedAt:    time.Now().UTC(),
+		UserID:       user.ID,
+		FeedID:       rule.FeedID,
+		TitleMatches: rule.TitleMatches,
+		Action:       *action,
+		Tag:          sql.NullString{String: *tag, Valid: *tag != ""},
+	})
+	if err != nil {
+		return fmt.Errorf("error creating rule: %v", err)
+	}
+	fmt.Printf("Rule %s added\n", created.ID)
+	return nil
+}
+
+func handlerRuleList(s *state, cmd command, user database.User) error {
+	rules, err := s.db.GetRulesForUser(context.Background(), user.ID)
+	if err != nil {
+		return fmt.Errorf("error retrieving rules: %v", err)
+	}
+	if len(rules) == 0 {
+		fmt.Println("No rules")
+		return nil
+	}
+	for _, rule := range rules {
+		fmt.Printf("ID: %s\n", rule.ID)
+		if rule.FeedUrl.Valid {
+			fmt.Printf("Feed: %s\n", rule.FeedUrl.String)
+		} else {
+			fmt.Println("Feed: every feed you follow")
+		}
+		fmt.Printf("Title matches: %s\n", rule.TitleMatches)
+		if rule.Action == ruleTag {
+			fmt.Printf("Action: %s %s\n", rule.Action, rule.Tag.String)
+		} else {
+			fmt.Printf("Action: %s\n", rule.Action)
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+func handlerRuleRemove(s *state, cmd command, user database.User) error {
+	if len(cmd.arg) < 1 {
+		return errors.New("rule remove requires one argument: id")
+	}
+	id, err := uuid.Parse(cmd.arg[0])
+	if err != nil {
+		return fmt.Errorf("invalid rule id %s: %v", cmd.arg[0], err)
+	}
+	removed, err := s.db.DeleteRule(context.Background(), database.DeleteRuleParams{ID: id, UserID: user.ID})
+	if err != nil {
+		return fmt.Errorf("error removing rule: %v", err)
+	}
+	if removed == 0 {
+		return fmt.Errorf("you have no rule with id %s", id)
+	}
+	fmt.Printf("Rule %s removed\n", id)
+	return nil
+}
+
+// handlerRuleTest lists the stored posts a rule would match, without
+// creating it or changing anything. Posts that are already hidden are
+// included, since a hide rule would match them too.
+func handlerRuleTest(s *state, cmd command, user database.User) error {
+	fs := flag.NewFlagSet("rule test", flag.ContinueOnError)
+	flags := addRuleFlags(fs)
+	if _, err := parseArgs(fs, cmd.arg); err != nil {
+		return err
+	}
+	rule, err := flags.rule(s, user)
+	if err != nil {
+		return err
+	}
+	posts, err := s.db.GetPostsForUser(context.Background(), database.GetPostsForUserParams{
+		UserID:        user.ID,
+		IncludeHidden: true,
+		Limit:         math.MaxInt32,
+	})
+	if err != nil {
+		return fmt.Errorf("error retrieving posts for user %v: %v", user.Name, err)
+	}
+	matched := 0
+	for _, post := range posts {
+		if !rule.matches(post.FeedID, post.Title) {
+			continue
+		}
+		matched++
+		fmt.Printf("%s  %s\n", post.ID, post.Title)
+	}
+	fmt.Printf("%d of %d posts match\n", matched, len(posts))
+	return nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/Lanrey-waju/gator.git/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestEvaluateRules(t *testing.T) {
+	alice, bob := uuid.New(), uuid.New()
+	feed, otherFeed := uuid.New(), uuid.New()
+	rules := compileRules([]database.Rule{
+		{ID: uuid.New(), UserID: alice, TitleMatches: `(?i)sponsored`, Action: ruleHide},
+		{ID: uuid.New(), UserID: alice, FeedID: uuid.NullUUID{UUID: feed, Valid: true}, TitleMatches: `^Release`, Action: ruleStar},
+		{ID: uuid.New(), UserID: alice, TitleMatches: `Go`, Action: ruleTag, Tag: sql.NullString{String: "golang", Valid: true}},
+		{ID: uuid.New(), UserID: bob, TitleMatches: `Release`, Action: ruleNotify},
+		{ID: uuid.New(), UserID: bob, TitleMatches: `(unclosed`, Action: ruleHide},
+	})
+	if len(rules) != 4 {
+		t.Fatalf("compileRules kept %d rules, want the 4 valid ones", len(rules))
+	}
+
+	tests := []struct {
+		name   string
+		feedID uuid.UUID
+		title  string
+		want   map[uuid.UUID]ruleOutcome
+	}{
+		{"no match", feed, "Weekly notes", map[uuid.UUID]ruleOutcome{}},
+		{"hide", otherFeed, "A SPONSORED post", map[uuid.UUID]ruleOutcome{alice: {hide: true}}},
+		{"tag only", feed, "Go tips", map[uuid.UUID]ruleOutcome{alice: {}}},
+		{"feed rule on its feed", feed, "Release 1.2", map[uuid.UUID]ruleOutcome{alice: {star: true}, bob: {notify: true}}},
+		{"feed rule on another feed", otherFeed, "Release 1.2", map[uuid.UUID]ruleOutcome{bob: {notify: true}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := evaluateRules(rules, tt.feedID, tt.title)
+			if len(got) != len(tt.want) {
+				t.Fatalf("evaluateRules = %+v, want %+v", got, tt.want)
+			}
+			for user, want := range tt.want {
+				if got[user] != want {
+					t.Errorf("outcome for %s = %+v, want %+v", user, got[user], want)
+				}
+			}
+		})
+	}
+}
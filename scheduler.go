@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/Lanrey-waju/gator.git/internal/config"
+)
+
+// scheduler runs a scrape pass every interval until it is stopped. It is
+// shared by agg, which runs in the foreground, and the daemon, which also
+// reports the scheduler's progress over its control socket.
+type scheduler struct {
+	s        *state
+	interval time.Duration
+	grace    time.Duration
+	reloads  chan chan error
+
+	mu        sync.Mutex
+	startedAt time.Time
+	lastTick  time.Time
+	passes    int
+	total     scrapeResult
+	lastRuns  map[string]feedRun
+}
+
+func newScheduler(s *state, interval, grace time.Duration) *scheduler {
+	return &scheduler{
+		s:        s,
+		interval: interval,
+		grace:    grace,
+		reloads:  make(chan chan error),
+		lastRuns: make(map[string]feedRun),
+	}
+}
+
+// run scrapes feeds until ctx is done. The pass in progress when ctx is
+// cancelled is allowed to finish, for up to the grace period. stop is
+// called once shutdown starts so that a second signal kills the process.
+func (sc *scheduler) run(ctx context.Context, stop func()) error {
+	work, cancelWork := context.WithCancel(context.Background())
+	defer cancelWork()
+	context.AfterFunc(ctx, func() {
+		stop()
+		slog.Info("shutting down, waiting for feeds in progress", "grace", sc.grace)
+		time.AfterFunc(sc.grace, cancelWork)
+	})
+
+	sc.mu.Lock()
+	sc.startedAt = time.Now()
+	sc.mu.Unlock()
+
+	ticker := time.NewTicker(sc.interval)
+	defer ticker.Stop()
+	for {
+		sc.mu.Lock()
+		sc.lastTick = time.Now()
+		sc.mu.Unlock()
+
+		result, err := scrapeFeeds(work, sc.s)
+		sc.record(result)
+		if err != nil && work.Err() == nil {
+			return fmt.Errorf("error aggregating feeds: %v", err)
+		}
+
+		if done, err := sc.waitForTick(ctx, work, ticker); done {
+			return err
+		}
+	}
+}
+
+// waitForTick blocks until the next pass is due, applying any reload
+// requests in the meantime. It reports true once the scheduler should stop.
+func (sc *scheduler) waitForTick(ctx, work context.Context, ticker *time.Ticker) (bool, error) {
+	for {
+		select {
+		case <-ctx.Done():
+			if work.Err() != nil {
+				return true, errors.New("grace period expired before feeds in progress finished")
+			}
+			return true, nil
+		case reply := <-sc.reloads:
+			reply <- sc.applyReload()
+		case <-ticker.C:
+			return false, nil
+		}
+	}
+}
+
+func (sc *scheduler) record(result scrapeResult) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.passes++
+	sc.total.add(result)
+	for _, run := range result.runs {
+		sc.lastRuns[run.URL] = run
+	}
+}
+
+// reload asks the scheduler to re-read the config file between passes.
+func (sc *scheduler) reload(ctx context.Context) error {
+	reply := make(chan error, 1)
+	select {
+	case sc.reloads <- reply:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-reply:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// applyReload replaces the config and the fetcher built from it, keeping
+// the fetcher's rate limits, robots.txt cache and backoff. The database
+// connection is kept, so a changed db_url needs a restart.
+func (sc *scheduler) applyReload() error {
+	cfg, err := config.Read(sc.s.cfg.Profile())
+	if err != nil {
+		return fmt.Errorf("error reading config: %v", err)
+	}
+	*sc.s.cfg = cfg
+	f := newFetcher(cfg.HTTP)
+	f.inheritHostState(sc.s.fetcher)
+	sc.s.fetcher = f
+	slog.Info("configuration reloaded")
+	return nil
+}
+
+func (sc *scheduler) logSummary() {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	slog.Info("aggregator stopped",
+		"uptime", time.Since(sc.startedAt).Round(time.Second),
+		"passes", sc.passes,
+		"feeds_fetched", sc.total.feeds,
+		"new_posts", sc.total.newPosts,
+		"failed_fetches", sc.total.failures)
+}
@@ -3,7 +3,10 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"log/slog"
+	"strconv"
 	"time"
 
 	"github.com/Lanrey-waju/gator.git/internal/database"
@@ -11,62 +14,273 @@ import (
 	"github.com/lib/pq"
 )
 
-func scrapeFeeds(s *state) error {
-	var err error
-	feeds, err := s.db.GetNextFeedsToFetch(context.Background(), 3)
+// feedsPerPass is how many due feeds agg fetches on each tick.
+const feedsPerPass = 3
+
+// feedLease is how long a claimed feed is reserved for the aggregator that
+// claimed it. It only matters if that aggregator dies mid-fetch.
+const feedLease = 10 * time.Minute
+
+// scrapeResult counts what a scrape pass did, for agg's exit summary and
+// the daemon's status report.
+type scrapeResult struct {
+	feeds    int
+	newPosts int
+	failures int
+	runs     []feedRun
+}
+
+// feedRun is the outcome of fetching one feed.
+type feedRun struct {
+	Feed     string    `json:"feed"`
+	URL      string    `json:"url"`
+	At       time.Time `json:"at"`
+	NewPosts int       `json:"new_posts"`
+	Error    string    `json:"error,omitempty"`
+}
+
+func (r *scrapeResult) add(other scrapeResult) {
+	r.feeds += other.feeds
+	r.newPosts += other.newPosts
+	r.failures += other.failures
+}
+
+func scrapeFeeds(ctx context.Context, s *state) (scrapeResult, error) {
+	started := time.Now()
+	defer func() { scrapePassDuration.Observe(time.Since(started).Seconds()) }()
+	result := scrapeResult{}
+	feeds, err := claimDueFeeds(ctx, s, feedsPerPass, started.UTC())
 	if err != nil {
-		return fmt.Errorf("error fetching feeds from database: %v", err)
+		return result, fmt.Errorf("error fetching feeds from database: %v", err)
 	}
 	for _, feed := range feeds {
-		s.db.MarkFeedFetched(context.Background(), database.MarkFeedFetchedParams{
-			LastFetchedAt: sql.NullTime{Time: time.Now().UTC(), Valid: true},
-			UpdatedAt:     time.Now().UTC(),
-			ID:            feed.ID,
-		})
-		rssFeed, err := fetchFeed(context.Background(), feed.Url)
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		refresh, err := refreshFeed(ctx, s, feed)
+		if err != nil {
+			return result, err
+		}
+		result.feeds++
+		result.newPosts += refresh.newPosts
+		run := feedRun{Feed: feed.Name, URL: feed.Url, At: time.Now().UTC(), NewPosts: refresh.newPosts}
+		if refresh.scrapeErr != nil {
+			result.failures++
+			run.Error = refresh.scrapeErr.Error()
+		}
+		result.runs = append(result.runs, run)
+	}
+	return result, nil
+}
+
+// claimDueFeeds takes a lease on up to limit feeds that are due and were
+// last fetched before fetchedBefore. Claiming is a single UPDATE ... FOR
+// UPDATE SKIP LOCKED, so several aggregators can share a database without
+// fetching the same feed twice; the lease is released by recordFeedStatus,
+// or expires if the aggregator dies.
+func claimDueFeeds(ctx context.Context, s *state, limit int32, fetchedBefore time.Time) ([]database.Feed, error) {
+	now := time.Now().UTC()
+	return s.db.ClaimNextFeedsToFetch(ctx, database.ClaimNextFeedsToFetchParams{
+		Now:            now,
+		LeaseExpiresAt: now.Add(feedLease),
+		FetchedBefore:  fetchedBefore,
+		Limit:          limit,
+	})
+}
+
+// claimFeed takes a lease on a specific feed, reporting false if another
+// aggregator holds it.
+func claimFeed(ctx context.Context, s *state, feed database.Feed) (database.Feed, bool, error) {
+	now := time.Now().UTC()
+	claimed, err := s.db.ClaimFeed(ctx, database.ClaimFeedParams{
+		Now:            now,
+		LeaseExpiresAt: now.Add(feedLease),
+		ID:             feed.ID,
+	})
+	if err == sql.ErrNoRows {
+		return feed, false, nil
+	}
+	return claimed, err == nil, err
+}
+
+// feedRefresh is what refreshFeed did with one feed. Failing to fetch or
+// parse the feed is not fatal to a scrape pass, so it is reported here
+// rather than as refreshFeed's error.
+type feedRefresh struct {
+	newPosts  int
+	scrapeErr error
+}
+
+// refreshFeed scrapes a feed that has been claimed and records the outcome.
+// Its error is only set if the database could not be updated.
+func refreshFeed(ctx context.Context, s *state, feed database.Feed) (feedRefresh, error) {
+	newPosts, scrapeErr := scrapeFeed(ctx, s, feed)
+	refresh := feedRefresh{newPosts: newPosts, scrapeErr: scrapeErr}
+	logger := slog.With("feed_id", feed.ID, "url", feed.Url)
+	if scrapeErr != nil {
+		logger.Warn("error scraping feed", "err", scrapeErr)
+	} else {
+		logger.Info("scraped feed", "new_posts", newPosts)
+	}
+	if err := recordFeedStatus(ctx, s, feed, scrapeErr); err != nil {
+		return refresh, fmt.Errorf("error recording status of feed %s: %v", feed.Url, err)
+	}
+	return refresh, nil
+}
+
+// scrapeFeed fetches feed and stores its new posts, returning how many
+// there were.
+func scrapeFeed(ctx context.Context, s *state, feed database.Feed) (int, error) {
+	creds, err := loadFeedCredentials(ctx, s, feed.ID)
+	if err != nil {
+		return 0, fmt.Errorf("error loading credentials: %v", err)
+	}
+	rssFeed, err := fetchFeed(ctx, s.fetcher, feed.Url, creds)
+	if err != nil {
+		return 0, err
+	}
+	var created []database.Post
+	// rules and webhooks run for whatever was stored, even if a later item
+	// fails
+	defer func() {
+		outcomes := applyIngestRules(ctx, s, feed, created)
+		notifyWebhooks(ctx, s, feed, created, outcomes)
+	}()
+	for _, item := range rssFeed.Channel.Item {
+		post, ok, err := createPost(ctx, s, feed, item)
 		if err != nil {
-			return fmt.Errorf("error fetching feed %s over the network: %v", feed.Url, err)
+			return len(created), err
+		}
+		if ok {
+			created = append(created, post)
 		}
-		for _, item := range rssFeed.Channel.Item {
-			publishedAt, err := parsePubDate(item)
-			if err != nil {
-				return fmt.Errorf("error parsing publish date: %v", err)
-			}
-			post, err := s.db.CreatePost(context.Background(), database.CreatePostParams{
-				ID:          uuid.New(),
-				CreatedAt:   time.Now().UTC(),
-				UpdatedAt:   time.Now().UTC(),
-				Title:       item.Title,
-				Url:         item.Link,
-				Description: sql.NullString{String: item.Description, Valid: true},
-				PublishedAt: sql.NullTime{Time: publishedAt, Valid: true},
-				FeedID:      feed.ID,
-			})
-			if err != nil {
-				if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
-					// 23505 is the error code for unique violation
-					fmt.Printf("duplicate post, URL already exists: %v\n", item.Title)
-					continue
-				} else {
-					return fmt.Errorf("error creating post %v: %v", post.Title, err)
-				}
-			}
+	}
+	return len(created), nil
+}
 
+// createPost stores item and its metadata in one transaction, so that an
+// interrupted scrape never leaves a post without its categories. It reports
+// false if the post was already stored.
+func createPost(ctx context.Context, s *state, feed database.Feed, item RSSItem) (database.Post, bool, error) {
+	publishedAt, err := parsePubDate(item)
+	if err != nil {
+		slog.Warn("using fetch time as publish date", "feed_id", feed.ID, "title", item.Title, "err", err)
+		publishedAt = time.Now().UTC()
+	}
+	tx, err := s.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return database.Post{}, false, err
+	}
+	defer tx.Rollback()
+	q := s.db.WithTx(tx)
+
+	author := itemAuthor(item)
+	post, err := q.CreatePost(ctx, database.CreatePostParams{
+		ID:          uuid.New(),
+		CreatedAt:   time.Now().UTC(),
+		UpdatedAt:   time.Now().UTC(),
+		Title:       item.Title,
+		Url:         item.Link,
+		Description: sql.NullString{String: item.Description, Valid: true},
+		PublishedAt: sql.NullTime{Time: publishedAt, Valid: true},
+		FeedID:      feed.ID,
+		Author:      sql.NullString{String: author, Valid: author != ""},
+		Content:     sql.NullString{String: item.Content, Valid: item.Content != ""},
+		Duration:    sql.NullString{String: item.Duration, Valid: item.Duration != ""},
+		Episode:     sql.NullString{String: item.Episode, Valid: item.Episode != ""},
+		ImageUrl:    sql.NullString{String: item.Image.Href, Valid: item.Image.Href != ""},
+	})
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			// 23505 is the error code for unique violation
+			slog.Debug("skipping duplicate post", "feed_id", feed.ID, "url", item.Link)
+			postsDuplicateTotal.Inc()
+			return database.Post{}, false, nil
 		}
+		return database.Post{}, false, fmt.Errorf("error creating post %v: %v", item.Title, err)
+	}
+	if err := savePostMetadata(ctx, q, post.ID, item); err != nil {
+		return database.Post{}, false, fmt.Errorf("error saving metadata for post %v: %v", item.Title, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return database.Post{}, false, fmt.Errorf("error creating post %v: %v", item.Title, err)
+	}
+	postsInsertedTotal.Inc()
+	return post, true, nil
+}
 
+// recordFeedStatus stores the outcome of a fetch so that "feed status" can
+// show it, postpones the feed when the server or robots.txt asked us to, and
+// releases the feed's lease. If the lease expired and another aggregator
+// has claimed the feed since, the outcome is left for that one to record.
+func recordFeedStatus(ctx context.Context, s *state, feed database.Feed, scrapeErr error) error {
+	params := database.SetFeedStatusParams{
+		LastStatus:     sql.NullString{String: "ok", Valid: true},
+		UpdatedAt:      time.Now().UTC(),
+		ID:             feed.ID,
+		LeaseExpiresAt: feed.LeaseExpiresAt,
+	}
+	var retryLater *retryLaterError
+	switch {
+	case scrapeErr == nil:
+	case errors.As(scrapeErr, &retryLater):
+		params.LastStatus.String = "postponed"
+		params.NextFetchAt = sql.NullTime{Time: retryLater.until.UTC(), Valid: true}
+	case errors.Is(scrapeErr, errRobotsDisallowed):
+		params.LastStatus.String = "blocked"
+		params.NextFetchAt = sql.NullTime{Time: time.Now().UTC().Add(robotsTTL), Valid: true}
+	default:
+		params.LastStatus.String = "error"
+	}
+	if scrapeErr != nil {
+		params.LastError = sql.NullString{String: scrapeErr.Error(), Valid: true}
+	}
+	n, err := s.db.SetFeedStatus(ctx, params)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		slog.Warn("lost the lease on feed before recording its status", "feed_id", feed.ID, "url", feed.Url)
 	}
 	return nil
 }
 
-func parsePubDate(item RSSItem) (time.Time, error) {
-	timeFormats := []string{time.RFC1123, time.RFC1123Z, time.RFC3339, time.RFC3339Nano, time.RFC822, time.RFC822Z, time.RFC850}
-	var parsedTime time.Time
-	var err error
-	for _, timeFormat := range timeFormats {
-		parsedTime, err = time.Parse(timeFormat, item.PubDate)
-		if err == nil {
-			return parsedTime, err
+func savePostMetadata(ctx context.Context, q *database.Queries, postID uuid.UUID, item RSSItem) error {
+	for _, category := range item.Categories {
+		err := q.CreatePostCategory(ctx, database.CreatePostCategoryParams{
+			PostID: postID,
+			Name:   category,
+		})
+		if err != nil {
+			return fmt.Errorf("error saving category %s: %v", category, err)
+		}
+	}
+	for _, enclosure := range item.Enclosures {
+		if enclosure.URL == "" {
+			continue
 		}
+		// feeds often publish a length of 0 or leave it blank when unknown
+		length, parseErr := strconv.ParseInt(enclosure.Length, 10, 64)
+		err := q.CreatePostEnclosure(ctx, database.CreatePostEnclosureParams{
+			ID:        uuid.New(),
+			CreatedAt: time.Now().UTC(),
+			PostID:    postID,
+			Url:       enclosure.URL,
+			MimeType:  sql.NullString{String: enclosure.Type, Valid: enclosure.Type != ""},
+			Length:    sql.NullInt64{Int64: length, Valid: parseErr == nil && length > 0},
+		})
+		if err != nil {
+			return fmt.Errorf("error saving enclosure %s: %v", enclosure.URL, err)
+		}
+	}
+	return nil
+}
+
+// itemAuthor prefers dc:creator, which usually holds a plain name, over the
+// RSS author element, which is meant to be an email address.
+func itemAuthor(item RSSItem) string {
+	if item.Creator != "" {
+		return item.Creator
 	}
-	return time.Time{}, fmt.Errorf("error parsing date for feed: %v", err)
+	return item.Author
 }
@@ -0,0 +1,16 @@
+-- name: UpsertFeedCredentials :exec
+INSERT INTO feed_credentials
+    (feed_id, created_at, updated_at, secret)
+VALUES
+    ($1, $2, $3, $4)
+ON CONFLICT (feed_id) DO UPDATE
+SET secret = EXCLUDED.secret, updated_at = EXCLUDED.updated_at;
+
+-- name: GetFeedCredentials :one
+SELECT secret
+FROM feed_credentials
+WHERE feed_id = $1;
+
+-- name: DeleteFeedCredentials :exec
+DELETE FROM feed_credentials
+WHERE feed_id = $1;
@@ -14,14 +14,55 @@ SELECT nf.id, nf.created_at, nf.updated_at, nf.user_id, nf.feed_id, u.name AS fo
 FROM new_follow nf JOIN users u ON nf.user_id = u.id JOIN feeds f ON nf.feed_id = f.id;
 
 -- name: GetFeedFollowsForUser :many
-SELECT ff.id, u.name AS follower, f.name AS feed
+SELECT ff.id, u.name AS follower, COALESCE(ff.custom_title, f.name)::text AS feed, f.url,
+    ff.priority, ff.muted, ff.notify
 FROM feed_follows ff
     JOIN users u ON ff.user_id = u.id
     JOIN feeds f ON ff.feed_id = f.id
-WHERE ff.user_id = $1;
+WHERE ff.user_id = $1
+ORDER BY ff.priority DESC, feed;
 
 
 -- name: DeleteFeedFollow :exec
 DELETE FROM feed_follows
 WHERE feed_follows.user_id = $1 AND
-    feed_follows.feed_id = $2;
\ No newline at end of file
+    feed_follows.feed_id = $2;
+
+-- name: GetFeedFollow :one
+SELECT *
+FROM feed_follows
+WHERE user_id = $1 AND feed_id = $2;
+
+-- name: AddFeedFollowTag :exec
+INSERT INTO feed_follow_tags
+    (feed_follow_id, tag)
+VALUES
+    ($1, $2)
+ON CONFLICT DO NOTHING;
+
+-- name: RemoveFeedFollowTag :execrows
+DELETE FROM feed_follow_tags
+WHERE feed_follow_id = $1 AND tag = $2;
+
+-- name: GetFeedFollowTagsForUser :many
+SELECT fft.feed_follow_id, fft.tag
+FROM feed_follow_tags fft
+    JOIN feed_follows ff ON ff.id = fft.feed_follow_id
+WHERE ff.user_id = $1
+ORDER BY fft.tag;
+
+-- name: UpdateFeedFollowSettings :one
+UPDATE feed_follows
+SET custom_title = $1, priority = $2, muted = $3, notify = $4, updated_at = $5
+WHERE id = $6
+RETURNING *;
+
+-- name: DeleteFeedFollowsWithoutAccess :execrows
+-- Unfollows a feed for everyone who can no longer see it.
+DELETE FROM feed_follows ff
+USING feeds f
+WHERE ff.feed_id = f.id
+    AND f.id = $1
+    AND f.visibility <> 'public'
+    AND ff.user_id <> f.user_id
+    AND NOT (f.visibility = 'shared' AND EXISTS (SELECT 1 FROM feed_shares fs WHERE fs.feed_id = f.id AND fs.user_id = ff.user_id));
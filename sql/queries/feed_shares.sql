@@ -0,0 +1,21 @@
+-- name: AddFeedShare :exec
+INSERT INTO feed_shares
+    (feed_id, user_id, created_at)
+VALUES
+    ($1, $2, $3)
+ON CONFLICT DO NOTHING;
+
+-- name: RemoveFeedShare :execrows
+DELETE FROM feed_shares
+WHERE feed_id = $1 AND user_id = $2;
+
+-- name: GetFeedShares :many
+SELECT u.name
+FROM feed_shares fs JOIN users u ON fs.user_id = u.id
+WHERE fs.feed_id = $1
+ORDER BY u.name;
+
+-- name: GetFeedSharesForUser :many
+SELECT feed_id
+FROM feed_shares
+WHERE user_id = $1;
@@ -1,13 +1,27 @@
 -- name: CreateFeed :one
 INSERT INTO feeds
-    (id, created_at, updated_at, name, url, user_id)
+    (id, created_at, updated_at, name, url, user_id, visibility)
 VALUES
-    ($1, $2, $3, $4, $5, $6)
+    ($1, $2, $3, $4, $5, $6, $7)
 RETURNING *;
 
 -- name: GetFeeds :many
-SELECT u.name as creator, f.name as feed_name, f.url
-FROM feeds f JOIN users u ON f.user_id = u.id;
+-- Lists the feeds the given user can see.
+SELECT u.name as creator, f.name as feed_name, f.url, f.visibility
+FROM feeds f JOIN users u ON f.user_id = u.id
+WHERE f.visibility = 'public'
+    OR f.user_id = $1
+    OR EXISTS (SELECT 1 FROM feed_shares fs WHERE fs.feed_id = f.id AND fs.user_id = $1);
+
+-- name: GetAllFeeds :many
+SELECT *
+FROM feeds
+ORDER BY name;
+
+-- name: GetFeedByID :one
+SELECT *
+FROM feeds
+WHERE id = $1;
 
 -- name: GetFeedByURL :one
 SELECT *
@@ -15,14 +29,64 @@ FROM feeds
 WHERE url = $1
 LIMIT 1;
 
--- name: MarkFeedFetched :exec
+-- name: SetFeedStatus :execrows
+-- Only the holder of the lease can record a status: lease_expires_at must
+-- still be the value it set when claiming the feed.
 UPDATE feeds
-SET last_fetched_at = $1, updated_at = $2
-WHERE id = $3;
+SET last_status = $1, last_error = $2, next_fetch_at = $3, updated_at = $4, lease_expires_at = NULL
+WHERE id = $5 AND lease_expires_at = $6;
 
--- name: GetNextFeedsToFetch :many
-SELECT *
+-- name: ClaimNextFeedsToFetch :many
+UPDATE feeds
+SET last_fetched_at = sqlc.arg(now)::timestamp,
+    updated_at = sqlc.arg(now)::timestamp,
+    lease_expires_at = sqlc.arg(lease_expires_at)::timestamp
+WHERE id IN (
+    SELECT id
+    FROM feeds
+    WHERE (next_fetch_at IS NULL OR next_fetch_at <= sqlc.arg(now)::timestamp)
+        AND (lease_expires_at IS NULL OR lease_expires_at <= sqlc.arg(now)::timestamp)
+        AND (last_fetched_at IS NULL OR last_fetched_at < sqlc.arg(fetched_before)::timestamp)
+    ORDER BY last_fetched_at ASC NULLS FIRST
+    LIMIT sqlc.arg('limit')
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: ClaimFeed :one
+UPDATE feeds
+SET last_fetched_at = sqlc.arg(now)::timestamp,
+    updated_at = sqlc.arg(now)::timestamp,
+    lease_expires_at = sqlc.arg(lease_expires_at)::timestamp
+WHERE id = sqlc.arg(id)
+    AND (lease_expires_at IS NULL OR lease_expires_at <= sqlc.arg(now)::timestamp)
+RETURNING *;
+
+-- name: CountFeedsDue :one
+SELECT COUNT(*)
 FROM feeds
-ORDER BY last_fetched_at ASC NULLS FIRST
-LIMIT
-$1;
\ No newline at end of file
+WHERE (next_fetch_at IS NULL OR next_fetch_at <= sqlc.arg(now)::timestamp)
+    AND (lease_expires_at IS NULL OR lease_expires_at <= sqlc.arg(now)::timestamp);
+
+-- name: RenameFeed :one
+UPDATE feeds
+SET name = $1, updated_at = $2
+WHERE id = $3
+RETURNING *;
+
+-- name: SetFeedURL :one
+-- The feed is fetched again on the next scrape, from its new address.
+UPDATE feeds
+SET url = $1, updated_at = $2, next_fetch_at = NULL, last_status = NULL, last_error = NULL
+WHERE id = $3
+RETURNING *;
+
+-- name: DeleteFeed :execrows
+DELETE FROM feeds
+WHERE id = $1;
+
+-- name: SetFeedVisibility :one
+UPDATE feeds
+SET visibility = $1, updated_at = $2
+WHERE id = $3
+RETURNING *;
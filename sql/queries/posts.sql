@@ -1,18 +1,115 @@
 -- name: CreatePost :one
 INSERT INTO posts
     (
-    id, created_at, updated_at, title, url, description, published_at, feed_id
+    id, created_at, updated_at, title, url, description, published_at, feed_id, author, content,
+    duration, episode, image_url
     )
 VALUES
     (
-        $1, $2, $3, $4, $5, $6, $7, $8
+        $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13
     )
 RETURNING *;
 
+-- name: GetPostByID :one
+SELECT *
+FROM posts
+WHERE id = $1;
+
 -- name: GetPostsForUser :many
-SELECT p.*
+SELECT p.*, COALESCE(ups.starred, FALSE)::boolean AS starred,
+    COALESCE(ff.custom_title, f.name)::text AS feed_name
 FROM posts p
     JOIN feed_follows ff ON ff.feed_id = p.feed_id
-WHERE ff.user_id = $1
-ORDER BY p.published_at DESC
-LIMIT $2;
\ No newline at end of file
+    JOIN feeds f ON f.id = p.feed_id
+    LEFT JOIN user_post_states ups ON ups.user_id = ff.user_id AND ups.post_id = p.id
+WHERE ff.user_id = sqlc.arg(user_id)
+    AND NOT ff.muted
+    AND (sqlc.arg(include_hidden)::boolean OR NOT COALESCE(ups.hidden, FALSE))
+    AND (NOT sqlc.arg(starred_only)::boolean OR COALESCE(ups.starred, FALSE))
+    AND (sqlc.arg(tag)::text = '' OR EXISTS (
+        SELECT 1
+        FROM feed_follow_tags fft
+        WHERE fft.feed_follow_id = ff.id AND (lower(fft.tag) = lower(sqlc.arg(tag)::text) OR starts_with(lower(fft.tag), lower(sqlc.arg(tag)::text) || '/'))
+    ) OR EXISTS (
+        SELECT 1
+        FROM user_post_tags upt
+        WHERE upt.user_id = ff.user_id AND upt.post_id = p.id AND lower(upt.tag) = lower(sqlc.arg(tag)::text)
+    ))
+    AND (sqlc.arg(author)::text = '' OR strpos(lower(p.author), lower(sqlc.arg(author)::text)) > 0)
+    AND (sqlc.arg(category)::text = '' OR EXISTS (
+        SELECT 1
+        FROM post_categories pc
+        WHERE pc.post_id = p.id AND lower(pc.name) = lower(sqlc.arg(category)::text)
+    ))
+ORDER BY ff.priority DESC, p.published_at DESC
+LIMIT sqlc.arg('limit')
+OFFSET sqlc.arg('offset');
+
+-- name: CreatePostCategory :exec
+INSERT INTO post_categories
+    (post_id, name)
+VALUES
+    ($1, $2)
+ON CONFLICT DO NOTHING;
+
+-- name: GetCategoriesForPosts :many
+SELECT *
+FROM post_categories
+WHERE post_id = ANY(sqlc.arg(post_ids)::uuid[])
+ORDER BY name;
+
+-- name: CreatePostEnclosure :exec
+INSERT INTO post_enclosures
+    (id, created_at, post_id, url, mime_type, length)
+VALUES
+    ($1, $2, $3, $4, $5, $6)
+ON CONFLICT DO NOTHING;
+
+-- name: GetEnclosuresForPost :many
+SELECT *
+FROM post_enclosures
+WHERE post_id = $1;
+
+-- name: GetEnclosuresForPosts :many
+SELECT *
+FROM post_enclosures
+WHERE post_id = ANY(sqlc.arg(post_ids)::uuid[]);
+
+-- name: MarkPostRead :exec
+INSERT INTO post_reads
+    (user_id, post_id, read_at)
+VALUES
+    ($1, $2, $3)
+ON CONFLICT DO NOTHING;
+
+-- name: GetUnreadPostsForUser :many
+SELECT p.id, p.title, p.url, p.description, p.published_at, p.author,
+    COALESCE(ff.custom_title, f.name)::text AS feed_name, f.url AS feed_url
+FROM posts p
+    JOIN feed_follows ff ON ff.feed_id = p.feed_id
+    JOIN feeds f ON f.id = p.feed_id
+WHERE ff.user_id = sqlc.arg(user_id)
+    AND NOT ff.muted
+    AND p.created_at >= sqlc.arg(since)
+    AND NOT EXISTS (
+        SELECT 1
+        FROM post_reads pr
+        WHERE pr.user_id = sqlc.arg(user_id) AND pr.post_id = p.id
+    )
+    AND NOT EXISTS (
+        SELECT 1
+        FROM user_post_states ups
+        WHERE ups.user_id = sqlc.arg(user_id) AND ups.post_id = p.id AND ups.hidden
+    )
+ORDER BY ff.priority DESC, feed_name, f.url, p.published_at DESC;
+
+-- name: DeletePosts :exec
+DELETE FROM posts;
+
+-- name: DeletePostsInFeedsOfUser :execrows
+DELETE FROM posts
+WHERE feed_id IN (
+    SELECT id
+    FROM feeds
+    WHERE user_id = $1
+);
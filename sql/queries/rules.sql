@@ -0,0 +1,73 @@
+-- name: CreateRule :one
+INSERT INTO rules
+    (id, created_at, updated_at, user_id, feed_id, title_matches, action, tag)
+VALUES
+    ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING *;
+
+-- name: GetRulesForUser :many
+SELECT r.*, f.url AS feed_url
+FROM rules r
+    LEFT JOIN feeds f ON f.id = r.feed_id
+WHERE r.user_id = $1
+ORDER BY r.created_at;
+
+-- name: GetRulesForFeed :many
+-- Rules without a feed apply to every feed their owner follows, and no
+-- rule applies to a feed its owner can no longer see.
+SELECT r.*
+FROM rules r
+    JOIN feeds f ON f.id = sqlc.arg(feed_id)::uuid
+WHERE (r.feed_id = sqlc.arg(feed_id)::uuid
+    OR (r.feed_id IS NULL AND EXISTS (
+        SELECT 1
+        FROM feed_follows ff
+        WHERE ff.user_id = r.user_id AND ff.feed_id = sqlc.arg(feed_id)::uuid
+    )))
+    AND (f.visibility = 'public' OR f.user_id = r.user_id OR (f.visibility = 'shared' AND EXISTS (
+        SELECT 1 FROM feed_shares fs WHERE fs.feed_id = f.id AND fs.user_id = r.user_id
+    )))
+ORDER BY r.created_at;
+
+-- name: DeleteRule :execrows
+DELETE FROM rules
+WHERE id = $1 AND user_id = $2;
+
+-- name: DeleteRulesWithoutAccess :execrows
+-- Removes the rules for a feed whose owners can no longer see it.
+DELETE FROM rules r
+USING feeds f
+WHERE r.feed_id = f.id
+    AND f.id = $1
+    AND f.visibility <> 'public'
+    AND r.user_id <> f.user_id
+    AND NOT (f.visibility = 'shared' AND EXISTS (SELECT 1 FROM feed_shares fs WHERE fs.feed_id = f.id AND fs.user_id = r.user_id));
+
+-- name: HidePost :exec
+INSERT INTO user_post_states
+    (user_id, post_id, hidden)
+VALUES
+    ($1, $2, TRUE)
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET hidden = TRUE;
+
+-- name: StarPost :exec
+INSERT INTO user_post_states
+    (user_id, post_id, starred)
+VALUES
+    ($1, $2, TRUE)
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET starred = TRUE;
+
+-- name: TagPost :exec
+INSERT INTO user_post_tags
+    (user_id, post_id, tag)
+VALUES
+    ($1, $2, $3)
+ON CONFLICT DO NOTHING;
+
+-- name: GetPostTagsForPosts :many
+SELECT *
+FROM user_post_tags
+WHERE user_id = $1 AND post_id = ANY(sqlc.arg(post_ids)::uuid[])
+ORDER BY tag;
@@ -0,0 +1,22 @@
+-- name: CreateSession :exec
+INSERT INTO sessions
+    (token_hash, user_id, created_at, expires_at)
+VALUES
+    ($1, $2, $3, $4);
+
+-- name: GetSession :one
+SELECT *
+FROM sessions
+WHERE token_hash = $1 AND expires_at > $2;
+
+-- name: DeleteSession :exec
+DELETE FROM sessions
+WHERE token_hash = $1;
+
+-- name: DeleteSessionsForUser :exec
+DELETE FROM sessions
+WHERE user_id = $1;
+
+-- name: DeleteExpiredSessions :exec
+DELETE FROM sessions
+WHERE expires_at <= $1;
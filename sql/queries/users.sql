@@ -1,11 +1,11 @@
 -- name: CreateUser :one
 INSERT INTO users
     (
-    id, created_at, updated_at, name
+    id, created_at, updated_at, name, is_admin
     )
 VALUES
     (
-        $1, $2, $3, $4
+        $1, $2, $3, $4, NOT EXISTS (SELECT 1 FROM users)
 )
 RETURNING *;
 
@@ -26,4 +26,38 @@ FROM users;
 SELECT name
 FROM users
 WHERE name = $1
-LIMIT 1;
\ No newline at end of file
+LIMIT 1;
+
+-- name: RenameUser :one
+UPDATE users
+SET name = $1, updated_at = $2
+WHERE id = $3
+RETURNING *;
+
+-- name: DeleteUser :execrows
+DELETE FROM users
+WHERE id = $1;
+
+-- name: GetUserStats :one
+SELECT
+    (SELECT COUNT(*) FROM feeds f WHERE f.user_id = $1) AS feeds_created,
+    (SELECT COUNT(*) FROM feed_follows ff WHERE ff.user_id = $1) AS follows,
+    (SELECT COUNT(*) FROM post_reads pr WHERE pr.user_id = $1) AS posts_read;
+
+-- name: GetOtherUserCounts :one
+-- Counts the users other than one, and how many of them are admins.
+SELECT
+    COUNT(*) AS users,
+    COUNT(*) FILTER (WHERE is_admin) AS admins
+FROM users
+WHERE id <> $1;
+
+-- name: SetUserPassword :exec
+UPDATE users
+SET password_hash = $1, updated_at = $2
+WHERE id = $3;
+
+-- name: SetUserAdmin :exec
+UPDATE users
+SET is_admin = $1, updated_at = $2
+WHERE id = $3;
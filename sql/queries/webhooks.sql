@@ -0,0 +1,56 @@
+-- name: CreateWebhook :one
+INSERT INTO webhooks
+    (id, created_at, updated_at, user_id, url, feed_id, match, secret)
+VALUES
+    ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING *;
+
+-- name: GetWebhooksForUser :many
+SELECT w.id, w.created_at, w.url, w.match, w.secret IS NOT NULL AS signed, f.url AS feed_url
+FROM webhooks w
+    LEFT JOIN feeds f ON f.id = w.feed_id
+WHERE w.user_id = $1
+ORDER BY w.created_at;
+
+-- name: GetWebhooksForFeed :many
+-- Webhooks without a feed fire for every feed their owner follows. Follows
+-- that are muted or have notifications turned off silence both kinds, and
+-- neither kind fires for a feed its owner can no longer see.
+SELECT w.*, ff.custom_title
+FROM webhooks w
+    JOIN feeds f ON f.id = sqlc.arg(feed_id)::uuid
+    LEFT JOIN feed_follows ff ON ff.user_id = w.user_id AND ff.feed_id = sqlc.arg(feed_id)::uuid
+WHERE (w.feed_id = sqlc.arg(feed_id)::uuid OR (w.feed_id IS NULL AND ff.id IS NOT NULL))
+    AND NOT COALESCE(ff.muted OR NOT ff.notify, FALSE)
+    AND (f.visibility = 'public' OR f.user_id = w.user_id OR (f.visibility = 'shared' AND EXISTS (
+        SELECT 1 FROM feed_shares fs WHERE fs.feed_id = f.id AND fs.user_id = w.user_id
+    )));
+
+-- name: DeleteWebhook :execrows
+DELETE FROM webhooks
+WHERE id = $1 AND user_id = $2;
+
+-- name: DeleteWebhooksWithoutAccess :execrows
+-- Removes the webhooks for a feed whose owners can no longer see it.
+DELETE FROM webhooks w
+USING feeds f
+WHERE w.feed_id = f.id
+    AND f.id = $1
+    AND f.visibility <> 'public'
+    AND w.user_id <> f.user_id
+    AND NOT (f.visibility = 'shared' AND EXISTS (SELECT 1 FROM feed_shares fs WHERE fs.feed_id = f.id AND fs.user_id = w.user_id));
+
+-- name: CreateWebhookDelivery :exec
+INSERT INTO webhook_deliveries
+    (id, created_at, webhook_id, post_id, attempts, status_code, error, delivered)
+VALUES
+    ($1, $2, $3, $4, $5, $6, $7, $8);
+
+-- name: GetWebhookDeliveries :many
+SELECT d.id, d.created_at, d.attempts, d.status_code, d.error, d.delivered, p.title AS post_title
+FROM webhook_deliveries d
+    JOIN webhooks w ON w.id = d.webhook_id
+    JOIN posts p ON p.id = d.post_id
+WHERE d.webhook_id = $1 AND w.user_id = $2
+ORDER BY d.created_at DESC
+LIMIT $3;
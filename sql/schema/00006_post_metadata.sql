@@ -0,0 +1,35 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE posts
+ADD author VARCHAR,
+ADD content TEXT;
+
+CREATE TABLE post_categories
+(
+    post_id UUID NOT NULL,
+    name VARCHAR NOT NULL,
+    PRIMARY KEY (post_id, name),
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+
+CREATE TABLE post_enclosures
+(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    post_id UUID NOT NULL,
+    url VARCHAR NOT NULL,
+    mime_type VARCHAR,
+    length BIGINT,
+    UNIQUE (post_id, url),
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE post_enclosures;
+DROP TABLE post_categories;
+ALTER TABLE posts
+DROP COLUMN content,
+DROP COLUMN author;
+-- +goose StatementEnd
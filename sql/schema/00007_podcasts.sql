@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE posts
+ADD duration VARCHAR,
+ADD episode VARCHAR,
+ADD image_url VARCHAR;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE posts
+DROP COLUMN image_url,
+DROP COLUMN episode,
+DROP COLUMN duration;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE feeds
+ADD next_fetch_at TIMESTAMP,
+ADD last_status VARCHAR,
+ADD last_error TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE feeds
+DROP COLUMN last_error,
+DROP COLUMN last_status,
+DROP COLUMN next_fetch_at;
+-- +goose StatementEnd
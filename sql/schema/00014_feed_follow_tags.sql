@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE feed_follow_tags
+(
+    feed_follow_id UUID NOT NULL,
+    tag TEXT NOT NULL,
+    PRIMARY KEY (feed_follow_id, tag),
+    FOREIGN KEY (feed_follow_id) REFERENCES feed_follows(id) ON DELETE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE feed_follow_tags;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE feed_follows
+    ADD COLUMN custom_title TEXT,
+    ADD COLUMN priority INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN muted BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN notify BOOLEAN NOT NULL DEFAULT TRUE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE feed_follows
+    DROP COLUMN custom_title,
+    DROP COLUMN priority,
+    DROP COLUMN muted,
+    DROP COLUMN notify;
+-- +goose StatementEnd
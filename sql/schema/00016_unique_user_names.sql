@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+-- register already refused duplicate names, but nothing enforced it; rename
+-- any duplicates that slipped through, keeping the oldest account's name
+UPDATE users u
+SET name = u.name || '-' || left(u.id::text, 8)
+WHERE EXISTS (
+    SELECT 1
+    FROM users older
+    WHERE older.name = u.name
+        AND (older.created_at, older.id) < (u.created_at, u.id)
+);
+
+ALTER TABLE users
+    ADD CONSTRAINT users_name_key UNIQUE (name);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users
+    DROP CONSTRAINT users_name_key;
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users
+    ADD COLUMN is_admin BOOLEAN NOT NULL DEFAULT false;
+
+-- the oldest account is the one that set gator up
+UPDATE users
+SET is_admin = true
+WHERE id = (
+    SELECT id
+    FROM users
+    ORDER BY created_at, id
+    LIMIT 1
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users
+    DROP COLUMN is_admin;
+-- +goose StatementEnd
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+-- private feeds are seen only by the user who added them; shared feeds also
+-- by the users in feed_shares
+ALTER TABLE feeds
+    ADD COLUMN visibility TEXT NOT NULL DEFAULT 'public'
+    CHECK (visibility IN ('public', 'private', 'shared'));
+
+CREATE TABLE feed_shares
+(
+    feed_id UUID NOT NULL,
+    user_id UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (feed_id, user_id),
+    FOREIGN KEY (feed_id) REFERENCES feeds(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE feed_shares;
+ALTER TABLE feeds
+    DROP COLUMN visibility;
+-- +goose StatementEnd
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Lanrey-waju/gator.git/internal/database"
+	"github.com/google/uuid"
+)
+
+// normalizeTag tidies a tag given on the command line or read from OPML.
+// Tags are folder paths: "tech/go" is the folder "go" inside "tech".
+func normalizeTag(tag string) string {
+	var parts []string
+	for _, part := range splitTag(tag) {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return joinTag(parts)
+}
+
+// tagEscaper escapes a folder name so that a "/" in it, as in an OPML
+// folder called "AC/DC", is not read as a nested folder.
+var tagEscaper = strings.NewReplacer(`\`, `\\`, "/", `\/`)
+
+// joinTag builds a tag from folder names, escaping each.
+func joinTag(folders []string) string {
+	escaped := make([]string, len(folders))
+	for i, folder := range folders {
+		escaped[i] = tagEscaper.Replace(folder)
+	}
+	return strings.Join(escaped, "/")
+}
+
+// splitTag is the inverse of joinTag: it splits tag on unescaped slashes
+// and unescapes the folder names.
+func splitTag(tag string) []string {
+	var folders []string
+	var folder strings.Builder
+	escaped := false
+	for _, r := range tag {
+		switch {
+		case escaped:
+			folder.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '/':
+			folders = append(folders, folder.String())
+			folder.Reset()
+		default:
+			folder.WriteRune(r)
+		}
+	}
+	return append(folders, folder.String())
+}
+
+// userFollow finds user's follow of the feed at feedURL.
+func userFollow(ctx context.Context, s *state, user database.User, feedURL string) (database.FeedFollow, error) {
+	feed, err := s.db.GetFeedByURL(ctx, feedURL)
+	if err != nil {
+		return database.FeedFollow{}, fmt.Errorf("error retrieving feed with url %s: %v", feedURL, err)
+	}
+	follow, err := s.db.GetFeedFollow(ctx, database.GetFeedFollowParams{UserID: user.ID, FeedID: feed.ID})
+	if err == sql.ErrNoRows {
+		return database.FeedFollow{}, fmt.Errorf("you don't follow %s", feedURL)
+	} else if err != nil {
+		return database.FeedFollow{}, fmt.Errorf("error retrieving feed follow: %v", err)
+	}
+	return follow, nil
+}
+
+// followTags returns the tags on each of user's follows, by follow ID.
+func followTags(ctx context.Context, s *state, userID uuid.UUID) (map[uuid.UUID][]string, error) {
+	rows, err := s.db.GetFeedFollowTagsForUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	tags := map[uuid.UUID][]string{}
+	for _, row := range rows {
+		tags[row.FeedFollowID] = append(tags[row.FeedFollowID], row.Tag)
+	}
+	return tags, nil
+}
+
+func handlerTagAdd(s *state, cmd command, user database.User) error {
+	if len(cmd.arg) < 2 {
+		return errors.New("tag add requires two arguments: url and tag")
+	}
+	tag := normalizeTag(cmd.arg[1])
+	if tag == "" {
+		return errors.New("tag cannot be empty")
+	}
+	follow, err := userFollow(context.Background(), s, user, cmd.arg[0])
+	if err != nil {
+		return err
+	}
+	err = s.db.AddFeedFollowTag(context.Background(), database.AddFeedFollowTagParams{
+		FeedFollowID: follow.ID,
+		Tag:          tag,
+	})
+	if err != nil {
+		return fmt.Errorf("error adding tag: %v", err)
+	}
+	fmt.Printf("Tagged %s with %s\n", cmd.arg[0], tag)
+	return nil
+}
+
+func handlerTagRemove(s *state, cmd command, user database.User) error {
+	if len(cmd.arg) < 2 {
+		return errors.New("tag remove requires two arguments: url and tag")
+	}
+	follow, err := userFollow(context.Background(), s, user, cmd.arg[0])
+	if err != nil {
+		return err
+	}
+	tag := normalizeTag(cmd.arg[1])
+	removed, err := s.db.RemoveFeedFollowTag(context.Background(), database.RemoveFeedFollowTagParams{
+		FeedFollowID: follow.ID,
+		Tag:          tag,
+	})
+	if err != nil {
+		return fmt.Errorf("error removing tag: %v", err)
+	}
+	if removed == 0 {
+		return fmt.Errorf("%s is not tagged with %s", cmd.arg[0], tag)
+	}
+	fmt.Printf("Removed tag %s from %s\n", tag, cmd.arg[0])
+	return nil
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/Lanrey-waju/gator.git/internal/database"
+	"github.com/lib/pq"
+)
+
+// isUniqueViolation reports whether err is Postgres refusing a duplicate.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// checkNotLastAdmin refuses to delete or demote user when they are the only
+// admin left among several users, since nobody could then manage the rest.
+// Deleting the very last user is fine: whoever registers next becomes admin.
+func checkNotLastAdmin(ctx context.Context, s *state, user database.User) error {
+	if !user.IsAdmin {
+		return nil
+	}
+	others, err := s.db.GetOtherUserCounts(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("error counting admins: %v", err)
+	}
+	if others.Users > 0 && others.Admins == 0 {
+		return fmt.Errorf("%s is the only admin; make someone else an admin first", user.Name)
+	}
+	return nil
+}
+
+// handlerUserRename renames the logged-in user.
+func handlerUserRename(s *state, cmd command, user database.User) error {
+	if len(cmd.arg) < 1 {
+		return errors.New("user rename requires one argument: the new name")
+	}
+	name := cmd.arg[0]
+	renamed, err := s.db.RenameUser(context.Background(), database.RenameUserParams{
+		Name:      name,
+		UpdatedAt: time.Now().UTC(),
+		ID:        user.ID,
+	})
+	if isUniqueViolation(err) {
+		return fmt.Errorf("user %s already exists", name)
+	} else if err != nil {
+		return fmt.Errorf("error renaming user: %v", err)
+	}
+	if err := s.cfg.SetUser(renamed.Name); err != nil {
+		return err
+	}
+	fmt.Printf("Renamed %s to %s\n", user.Name, renamed.Name)
+	return nil
+}
+
+// handlerUserDelete deletes one user along with everything they own. The
+// feeds they added go too, for everyone, so it asks first. Users can delete
+// themselves; only admins can delete anyone else.
+func handlerUserDelete(s *state, cmd command, current database.User) error {
+	fs := flag.NewFlagSet("user delete", flag.ContinueOnError)
+	yes := fs.Bool("yes", false, "don't ask for confirmation")
+	args, err := parseArgs(fs, cmd.arg)
+	if err != nil {
+		return err
+	}
+	if len(args) < 1 {
+		return errors.New("user delete requires one argument: the username")
+	}
+	user, err := s.db.GetUserByName(context.Background(), args[0])
+	if err != nil {
+		return fmt.Errorf("error retrieving user %s: %v", args[0], err)
+	}
+	if user.ID != current.ID && !current.IsAdmin {
+		return errors.New("only admins can delete other users")
+	}
+	if err := checkNotLastAdmin(context.Background(), s, user); err != nil {
+		return err
+	}
+	if !*yes {
+		stats, err := s.db.GetUserStats(context.Background(), user.ID)
+		if err != nil {
+			return fmt.Errorf("error retrieving user %s: %v", user.Name, err)
+		}
+		prompt := fmt.Sprintf("Delete %s, their %d follows and the %d feeds they added, for every follower?",
+			user.Name, stats.Follows, stats.FeedsCreated)
+		ok, err := confirm(prompt)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New("user delete cancelled")
+		}
+	}
+	if _, err := s.db.DeleteUser(context.Background(), user.ID); err != nil {
+		return fmt.Errorf("error deleting user %s: %v", user.Name, err)
+	}
+	if user.Name == s.cfg.CurrentUserName {
+		if err := s.cfg.SetUser(""); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Deleted %s\n", user.Name)
+	return nil
+}
+
+func handlerWhoami(s *state, cmd command, user database.User) error {
+	stats, err := s.db.GetUserStats(context.Background(), user.ID)
+	if err != nil {
+		return fmt.Errorf("error retrieving stats for user %s: %v", user.Name, err)
+	}
+	fmt.Printf("Name: %s\n", user.Name)
+	fmt.Printf("ID: %s\n", user.ID)
+	if user.IsAdmin {
+		fmt.Println("Role: admin")
+	}
+	fmt.Printf("Member since: %s\n", user.CreatedAt.Local().Format(time.RFC1123))
+	fmt.Printf("Feeds created: %d\n", stats.FeedsCreated)
+	fmt.Printf("Feeds followed: %d\n", stats.Follows)
+	fmt.Printf("Posts read: %d\n", stats.PostsRead)
+	return nil
+}
+
+// handlerUserAdmin makes another user an admin or, with --revoke, takes it
+// away again.
+func handlerUserAdmin(s *state, cmd command, current database.User) error {
+	fs := flag.NewFlagSet("user admin", flag.ContinueOnError)
+	revoke := fs.Bool("revoke", false, "take admin rights away instead")
+	args, err := parseArgs(fs, cmd.arg)
+	if err != nil {
+		return err
+	}
+	if len(args) < 1 {
+		return errors.New("user admin requires one argument: the username")
+	}
+	user, err := s.db.GetUserByName(context.Background(), args[0])
+	if err != nil {
+		return fmt.Errorf("error retrieving user %s: %v", args[0], err)
+	}
+	if *revoke && user.ID == current.ID {
+		return errors.New("you can't revoke your own admin rights; ask another admin")
+	}
+	if *revoke {
+		if err := checkNotLastAdmin(context.Background(), s, user); err != nil {
+			return err
+		}
+	}
+	err = s.db.SetUserAdmin(context.Background(), database.SetUserAdminParams{
+		IsAdmin:   !*revoke,
+		UpdatedAt: time.Now().UTC(),
+		ID:        user.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("error updating user %s: %v", user.Name, err)
+	}
+	if *revoke {
+		fmt.Printf("%s is no longer an admin\n", user.Name)
+	} else {
+		fmt.Printf("%s is now an admin\n", user.Name)
+	}
+	return nil
+}
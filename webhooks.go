@@ -0,0 +1,415 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Lanrey-waju/gator.git/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	// webhookAttempts is how many times a delivery is tried before it is
+	// logged as failed.
+	webhookAttempts = 3
+	// webhookConcurrency is how many deliveries for one feed run at once.
+	webhookConcurrency = 4
+	// webhookDeadline bounds all deliveries for one feed, so that a slow
+	// receiver cannot stall a scrape pass past the feed's lease.
+	webhookDeadline = time.Minute
+	// webhookSignatureHeader carries the hex HMAC-SHA256 of the request body,
+	// keyed with the webhook's secret, as "sha256=<hex>".
+	webhookSignatureHeader = "X-Gator-Signature"
+)
+
+// webhookRetryDelay is the wait before the first retry; it doubles for each
+// retry after that. It is a variable so that tests can shorten it.
+var webhookRetryDelay = 2 * time.Second
+
+// webhookPayload is the JSON body posted for each new post. Text and Content
+// repeat the same one-line summary so that Slack, Matrix and Discord
+// incoming webhooks can display it without a relay in between.
+type webhookPayload struct {
+	Event   string      `json:"event"`
+	Text    string      `json:"text"`
+	Content string      `json:"content"`
+	Feed    webhookFeed `json:"feed"`
+	Post    webhookPost `json:"post"`
+}
+
+type webhookFeed struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+	URL  string    `json:"url"`
+}
+
+type webhookPost struct {
+	ID          uuid.UUID  `json:"id"`
+	Title       string     `json:"title"`
+	URL         string     `json:"url"`
+	Author      string     `json:"author,omitempty"`
+	Description string     `json:"description,omitempty"`
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+}
+
+func newWebhookPayload(feed database.Feed, post database.Post) webhookPayload {
+	summary := fmt.Sprintf("%s: %s %s", feed.Name, post.Title, post.Url)
+	payload := webhookPayload{
+		Event:   "post.created",
+		Text:    summary,
+		Content: summary,
+		Feed:    webhookFeed{ID: feed.ID, Name: feed.Name, URL: feed.Url},
+		Post: webhookPost{
+			ID:          post.ID,
+			Title:       post.Title,
+			URL:         post.Url,
+			Author:      post.Author.String,
+			Description: post.Description.String,
+		},
+	}
+	if post.PublishedAt.Valid {
+		payload.Post.PublishedAt = &post.PublishedAt.Time
+	}
+	return payload
+}
+
+// signWebhook returns the value of the signature header for body.
+func signWebhook(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookResult is the outcome of delivering one payload.
+type webhookResult struct {
+	attempts   int
+	statusCode int
+	err        error
+}
+
+// postWebhook delivers body to hookURL, retrying network errors, 429s and
+// server errors with a doubling delay, or after the Retry-After a 429 asks
+// for. It gives up early if the next attempt would fall after ctx's
+// deadline. Webhooks are not feeds, so the request skips the per-host
+// limiter and robots.txt.
+func (f *fetcher) postWebhook(ctx context.Context, hookURL string, secret []byte, deliveryID uuid.UUID, body []byte) webhookResult {
+	result := webhookResult{}
+	delay := webhookRetryDelay
+	var wait time.Duration
+	for result.attempts < webhookAttempts {
+		if result.attempts > 0 {
+			if deadline, ok := ctx.Deadline(); ok && time.Now().Add(wait).After(deadline) {
+				return result
+			}
+			select {
+			case <-ctx.Done():
+				return result
+			case <-time.After(wait):
+			}
+		}
+		wait = delay
+		delay *= 2
+		result.attempts++
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, hookURL, bytes.NewReader(body))
+		if err != nil {
+			result.err = err
+			return result
+		}
+		req.Header.Set("User-Agent", f.userAgent)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Gator-Event", "post.created")
+		req.Header.Set("X-Gator-Delivery", deliveryID.String())
+		if len(secret) > 0 {
+			req.Header.Set(webhookSignatureHeader, signWebhook(secret, body))
+		}
+		resp, err := f.client.Do(req)
+		if err != nil {
+			result.statusCode = 0
+			result.err = err
+			continue
+		}
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		resp.Body.Close()
+		result.statusCode = resp.StatusCode
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			result.err = nil
+			return result
+		}
+		result.err = fmt.Errorf("%w: %s", errBadStatus, resp.Status)
+		if resp.StatusCode == http.StatusTooManyRequests {
+			if header := resp.Header.Get("Retry-After"); header != "" {
+				wait = time.Until(retryAfter(header))
+			}
+		} else if resp.StatusCode < 500 {
+			// the receiver rejected the payload; sending it again won't help
+			return result
+		}
+	}
+	return result
+}
+
+// notifyWebhooks delivers posts, which were just stored for feed, to every
+// webhook that covers the feed and whose pattern matches the post title.
+// outcomes holds what each user's rules decided: posts a rule hid are not
+// sent, and posts a notify rule picked are sent whatever the pattern.
+// Deliveries run webhookConcurrency at a time and all within
+// webhookDeadline. Delivery problems are logged rather than returned so
+// that a broken receiver never marks the feed itself as failing.
+func notifyWebhooks(ctx context.Context, s *state, feed database.Feed, posts []database.Post, outcomes map[uuid.UUID]map[uuid.UUID]ruleOutcome) {
+	if len(posts) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, webhookDeadline)
+	defer cancel()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	slots := make(chan struct{}, webhookConcurrency)
+	hooks, err := s.db.GetWebhooksForFeed(ctx, feed.ID)
+	if err != nil {
+		slog.Warn("error loading webhooks", "feed_id", feed.ID, "err", err)
+		return
+	}
+	for _, hook := range hooks {
+		logger := slog.With("webhook_id", hook.ID, "feed_id", feed.ID)
+		var match *regexp.Regexp
+		if hook.Match.Valid {
+			if match, err = regexp.Compile(hook.Match.String); err != nil {
+				logger.Warn("skipping webhook with invalid pattern", "err", err)
+				continue
+			}
+		}
+		var secret []byte
+		if len(hook.Secret) > 0 {
+			if secret, err = openSecret(s, hook.Secret, webhookSecretAAD(hook.ID)); err != nil {
+				logger.Warn("skipping webhook whose secret cannot be read", "err", err)
+				continue
+			}
+		}
+		for _, post := range posts {
+			outcome := outcomes[post.ID][hook.UserID]
+			if outcome.hide {
+				continue
+			}
+			if match != nil && !match.MatchString(post.Title) && !outcome.notify {
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case slots <- struct{}{}:
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-slots }()
+				deliverWebhook(ctx, s, hook, secret, feed, post, logger)
+			}()
+		}
+	}
+}
+
+func deliverWebhook(ctx context.Context, s *state, hook database.GetWebhooksForFeedRow, secret []byte, feed database.Feed, post database.Post, logger *slog.Logger) {
+	// the payload names the feed the way the webhook's owner does
+	if hook.CustomTitle.Valid {
+		feed.Name = hook.CustomTitle.String
+	}
+	body, err := json.Marshal(newWebhookPayload(feed, post))
+	if err != nil {
+		logger.Warn("error encoding webhook payload", "post_id", post.ID, "err", err)
+		return
+	}
+	deliveryID := uuid.New()
+	result := s.fetcher.postWebhook(ctx, hook.Url, secret, deliveryID, body)
+	// record the delivery even if it ran out of time
+	ctx = context.WithoutCancel(ctx)
+	delivery := database.CreateWebhookDeliveryParams{
+		ID:         deliveryID,
+		CreatedAt:  time.Now().UTC(),
+		WebhookID:  hook.ID,
+		PostID:     post.ID,
+		Attempts:   int32(result.attempts),
+		StatusCode: sql.NullInt32{Int32: int32(result.statusCode), Valid: result.statusCode != 0},
+		Delivered:  result.err == nil && result.attempts > 0,
+	}
+	if result.err != nil {
+		delivery.Error = sql.NullString{String: result.err.Error(), Valid: true}
+	}
+	if delivery.Delivered {
+		webhookDeliveriesTotal.WithLabelValues("delivered").Inc()
+		logger.Debug("delivered webhook", "post_id", post.ID, "attempts", result.attempts)
+	} else {
+		webhookDeliveriesTotal.WithLabelValues("failed").Inc()
+		logger.Warn("webhook delivery failed", "post_id", post.ID, "attempts", result.attempts, "err", result.err)
+	}
+	if err := s.db.CreateWebhookDelivery(ctx, delivery); err != nil {
+		logger.Warn("error recording webhook delivery", "post_id", post.ID, "err", err)
+	}
+}
+
+// webhookSecretAAD binds a sealed signing secret to its webhook.
+func webhookSecretAAD(hookID uuid.UUID) []byte {
+	return []byte("webhooks:" + hookID.String())
+}
+
+func handlerWebhookAdd(s *state, cmd command, user database.User) error {
+	fs := flag.NewFlagSet("webhook add", flag.ContinueOnError)
+	feedURL := fs.String("feed", "", "only fire for this feed; by default every feed you follow")
+	pattern := fs.String("match", "", "only fire for posts whose title matches this regular expression")
+	sign := fs.Bool("secret", false, "sign deliveries with a secret, which is prompted for")
+	args, err := parseArgs(fs, cmd.arg)
+	if err != nil {
+		return err
+	}
+	if len(args) < 1 {
+		return errors.New("webhook add requires one argument: url")
+	}
+	hookURL, err := url.Parse(args[0])
+	if err != nil || (hookURL.Scheme != "http" && hookURL.Scheme != "https") || hookURL.Host == "" {
+		return fmt.Errorf("webhook url %s must be an absolute http or https URL", args[0])
+	}
+
+	params := database.CreateWebhookParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+		UserID:    user.ID,
+		Url:       hookURL.String(),
+	}
+	if *feedURL != "" {
+		feed, err := visibleFeed(context.Background(), s, user, *feedURL)
+		if err != nil {
+			return err
+		}
+		params.FeedID = uuid.NullUUID{UUID: feed.ID, Valid: true}
+	}
+	if *pattern != "" {
+		if _, err := regexp.Compile(*pattern); err != nil {
+			return fmt.Errorf("invalid --match pattern: %v", err)
+		}
+		params.Match = sql.NullString{String: *pattern, Valid: true}
+	}
+	if *sign {
+		secret, err := promptSecret("Signing secret: ")
+		if err != nil {
+			return fmt.Errorf("error reading secret: %v", err)
+		}
+		if secret == "" {
+			return errors.New("the signing secret cannot be empty")
+		}
+		if params.Secret, err = sealSecret(s, []byte(secret), webhookSecretAAD(params.ID)); err != nil {
+			return fmt.Errorf("error saving secret: %v", err)
+		}
+	}
+
+	hook, err := s.db.CreateWebhook(context.Background(), params)
+	if err != nil {
+		return fmt.Errorf("error creating webhook: %v", err)
+	}
+	fmt.Printf("Webhook %s added\n", hook.ID)
+	return nil
+}
+
+func handlerWebhookList(s *state, cmd command, user database.User) error {
+	hooks, err := s.db.GetWebhooksForUser(context.Background(), user.ID)
+	if err != nil {
+		return fmt.Errorf("error retrieving webhooks: %v", err)
+	}
+	if len(hooks) == 0 {
+		fmt.Println("No webhooks")
+		return nil
+	}
+	for _, hook := range hooks {
+		fmt.Printf("ID: %s\n", hook.ID)
+		fmt.Printf("URL: %s\n", hook.Url)
+		if hook.FeedUrl.Valid {
+			fmt.Printf("Feed: %s\n", hook.FeedUrl.String)
+		} else {
+			fmt.Println("Feed: every feed you follow")
+		}
+		if hook.Match.Valid {
+			fmt.Printf("Match: %s\n", hook.Match.String)
+		}
+		fmt.Printf("Signed: %t\n", hook.Signed)
+		fmt.Println()
+	}
+	return nil
+}
+
+func handlerWebhookRemove(s *state, cmd command, user database.User) error {
+	if len(cmd.arg) < 1 {
+		return errors.New("webhook remove requires one argument: id")
+	}
+	id, err := uuid.Parse(cmd.arg[0])
+	if err != nil {
+		return fmt.Errorf("invalid webhook id %s: %v", cmd.arg[0], err)
+	}
+	removed, err := s.db.DeleteWebhook(context.Background(), database.DeleteWebhookParams{ID: id, UserID: user.ID})
+	if err != nil {
+		return fmt.Errorf("error removing webhook: %v", err)
+	}
+	if removed == 0 {
+		return fmt.Errorf("you have no webhook with id %s", id)
+	}
+	fmt.Printf("Webhook %s removed\n", id)
+	return nil
+}
+
+func handlerWebhookLog(s *state, cmd command, user database.User) error {
+	if len(cmd.arg) < 1 {
+		return errors.New("webhook log requires an id and an optional limit")
+	}
+	id, err := uuid.Parse(cmd.arg[0])
+	if err != nil {
+		return fmt.Errorf("invalid webhook id %s: %v", cmd.arg[0], err)
+	}
+	limit := 20
+	if len(cmd.arg) > 1 {
+		if limit, err = strconv.Atoi(cmd.arg[1]); err != nil {
+			return fmt.Errorf("invalid limit %s: %v", cmd.arg[1], err)
+		}
+		if limit < 1 {
+			return fmt.Errorf("invalid limit %d: webhook log shows at least one delivery", limit)
+		}
+	}
+	deliveries, err := s.db.GetWebhookDeliveries(context.Background(), database.GetWebhookDeliveriesParams{
+		WebhookID: id,
+		UserID:    user.ID,
+		Limit:     int32(limit),
+	})
+	if err != nil {
+		return fmt.Errorf("error retrieving deliveries: %v", err)
+	}
+	if len(deliveries) == 0 {
+		fmt.Println("No deliveries")
+		return nil
+	}
+	for _, delivery := range deliveries {
+		outcome := "delivered"
+		if !delivery.Delivered {
+			outcome = "failed: " + delivery.Error.String
+		}
+		status := "no response"
+		if delivery.StatusCode.Valid {
+			status = strconv.Itoa(int(delivery.StatusCode.Int32))
+		}
+		fmt.Printf("%s  %s\n  %s (%s, %d attempts)\n",
+			delivery.CreatedAt.Local().Format(time.RFC1123), delivery.PostTitle, outcome, status, delivery.Attempts)
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Lanrey-waju/gator.git/internal/config"
+	"github.com/google/uuid"
+)
+
+// webhookReceiver is an httptest server that answers each delivery with the
+// next of statuses, repeating the last one, and remembers what it was sent.
+type webhookReceiver struct {
+	mu       sync.Mutex
+	statuses []int
+	header   http.Header
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func newWebhookReceiver(t *testing.T, statuses ...int) (*webhookReceiver, *httptest.Server) {
+	t.Helper()
+	rec := &webhookReceiver{statuses: statuses, header: http.Header{}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		rec.requests = append(rec.requests, r)
+		rec.bodies = append(rec.bodies, body)
+		status := rec.statuses[min(len(rec.requests), len(rec.statuses))-1]
+		for name, values := range rec.header {
+			w.Header()[name] = values
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return rec, srv
+}
+
+func shortenWebhookRetries(t *testing.T) {
+	t.Helper()
+	old := webhookRetryDelay
+	webhookRetryDelay = time.Millisecond
+	t.Cleanup(func() { webhookRetryDelay = old })
+}
+
+func TestPostWebhookSignsBody(t *testing.T) {
+	rec, srv := newWebhookReceiver(t, http.StatusNoContent)
+	f := newFetcher(config.HTTPConfig{})
+	secret := []byte("s3cret")
+	body := []byte(`{"event":"post.created"}`)
+	deliveryID := uuid.New()
+
+	result := f.postWebhook(context.Background(), srv.URL, secret, deliveryID, body)
+	if result.err != nil || result.attempts != 1 || result.statusCode != http.StatusNoContent {
+		t.Fatalf("postWebhook = %+v, want one successful attempt", result)
+	}
+
+	req := rec.requests[0]
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(rec.bodies[0])
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if got := req.Header.Get(webhookSignatureHeader); got != want {
+		t.Errorf("%s = %q, want %q", webhookSignatureHeader, got, want)
+	}
+	if string(rec.bodies[0]) != string(body) {
+		t.Errorf("body = %q, want %q", rec.bodies[0], body)
+	}
+	if got := req.Header.Get("X-Gator-Delivery"); got != deliveryID.String() {
+		t.Errorf("X-Gator-Delivery = %q, want %q", got, deliveryID)
+	}
+	if got := req.Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+}
+
+func TestPostWebhookUnsigned(t *testing.T) {
+	rec, srv := newWebhookReceiver(t, http.StatusOK)
+	f := newFetcher(config.HTTPConfig{})
+	f.postWebhook(context.Background(), srv.URL, nil, uuid.New(), []byte("{}"))
+	if got := rec.requests[0].Header.Get(webhookSignatureHeader); got != "" {
+		t.Errorf("unsigned webhook sent %s: %q", webhookSignatureHeader, got)
+	}
+}
+
+func TestPostWebhookRetries(t *testing.T) {
+	shortenWebhookRetries(t)
+	tests := []struct {
+		name         string
+		statuses     []int
+		wantAttempts int
+		wantStatus   int
+		wantErr      bool
+	}{
+		{"success first time", []int{200}, 1, 200, false},
+		{"retries a 503", []int{503, 200}, 2, 200, false},
+		{"retries a 429", []int{429, 429, 202}, 3, 202, false},
+		{"gives up after three 500s", []int{500}, 3, 500, true},
+		{"does not retry a 400", []int{400, 200}, 1, 400, true},
+		{"does not retry a 404", []int{404}, 1, 404, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, srv := newWebhookReceiver(t, tt.statuses...)
+			f := newFetcher(config.HTTPConfig{})
+			result := f.postWebhook(context.Background(), srv.URL, []byte("k"), uuid.New(), []byte("{}"))
+			if result.attempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", result.attempts, tt.wantAttempts)
+			}
+			if len(rec.requests) != tt.wantAttempts {
+				t.Errorf("receiver saw %d requests, want %d", len(rec.requests), tt.wantAttempts)
+			}
+			if result.statusCode != tt.wantStatus {
+				t.Errorf("statusCode = %d, want %d", result.statusCode, tt.wantStatus)
+			}
+			if tt.wantErr && !errors.Is(result.err, errBadStatus) {
+				t.Errorf("err = %v, want errBadStatus", result.err)
+			}
+			if !tt.wantErr && result.err != nil {
+				t.Errorf("err = %v, want nil", result.err)
+			}
+		})
+	}
+}
+
+func TestPostWebhookHonoursRetryAfter(t *testing.T) {
+	shortenWebhookRetries(t)
+	rec, srv := newWebhookReceiver(t, http.StatusTooManyRequests, http.StatusOK)
+	rec.header.Set("Retry-After", "1")
+	f := newFetcher(config.HTTPConfig{})
+
+	started := time.Now()
+	result := f.postWebhook(context.Background(), srv.URL, nil, uuid.New(), []byte("{}"))
+	if result.err != nil || result.attempts != 2 {
+		t.Fatalf("postWebhook = %+v, want success on the second attempt", result)
+	}
+	if elapsed := time.Since(started); elapsed < time.Second {
+		t.Errorf("retried after %s, want at least the 1s Retry-After", elapsed)
+	}
+}
+
+func TestPostWebhookGivesUpPastDeadline(t *testing.T) {
+	rec, srv := newWebhookReceiver(t, http.StatusTooManyRequests)
+	rec.header.Set("Retry-After", "3600")
+	f := newFetcher(config.HTTPConfig{})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	started := time.Now()
+	result := f.postWebhook(ctx, srv.URL, nil, uuid.New(), []byte("{}"))
+	if result.attempts != 1 || result.statusCode != http.StatusTooManyRequests || !errors.Is(result.err, errBadStatus) {
+		t.Errorf("postWebhook = %+v, want a single failed attempt", result)
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("waited %s for a retry that could not happen in time", elapsed)
+	}
+}